@@ -1,8 +1,12 @@
 package cache_test
 
 import (
+	"context"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
@@ -328,6 +332,332 @@ func (st *CacheAbsentKeysLoaderSuite) TestViaHGetKeysAndFields_AbsentDuplicatedK
 	st.Require().NoError(err, "No error expected on loading duplicated non-exist keys")
 }
 
+func (st *CacheAbsentKeysLoaderSuite) TestContextLoader_ReceivesCallerContext() {
+	type ctxKey struct{}
+	ctx := context.WithValue(st.ctx, ctxKey{}, "request-scoped-value")
+	keysToLoad := []string{faker.RandomString(5), faker.RandomString(5)}
+
+	var dst map[string]string
+	st.Require().NoError(
+		st.cache.
+			WithContextAbsentKeysLoader(func(loaderCtx context.Context, absentKeys ...string) (interface{}, error) {
+				st.Require().Equal("request-scoped-value", loaderCtx.Value(ctxKey{}), "caller context expected in the loader")
+				return st.keysToMap(absentKeys...), nil
+			}).
+			Get(ctx, &dst, keysToLoad...),
+		"No error expected for loading with the context loader",
+	)
+	st.Require().EqualValues(st.keysToMap(keysToLoad...), dst, "unexpected loaded items")
+	st.checkElementsInCache(st.keysToMap(keysToLoad...))
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestContextLoader_ReceivesCallerDeadline() {
+	ctx, cancel := context.WithTimeout(st.ctx, time.Minute)
+	defer cancel()
+	expectedDeadline, _ := ctx.Deadline()
+
+	var dst map[string]string
+	st.Require().NoError(
+		st.cache.
+			WithContextAbsentKeysLoader(func(loaderCtx context.Context, absentKeys ...string) (interface{}, error) {
+				deadline, ok := loaderCtx.Deadline()
+				st.Require().True(ok, "deadline expected in the loader context")
+				st.Require().Equal(expectedDeadline, deadline, "unexpected deadline")
+				return st.keysToMap(absentKeys...), nil
+			}).
+			HGetFieldsForKey(ctx, &dst, faker.RandomString(5), faker.RandomString(5)),
+		"No error expected",
+	)
+	st.Require().Len(dst, 1, "loaded field expected")
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestContextLoader_ReceivesCallerDeadlineWithCoalescing() {
+	ctx, cancel := context.WithTimeout(st.ctx, time.Minute)
+	defer cancel()
+	expectedDeadline, _ := ctx.Deadline()
+	coalescingCache := cache.NewCache(cache.Options{
+		Redis:         st.client,
+		Marshaller:    st.marshaller,
+		CoalesceLoads: true,
+	})
+
+	var dst map[string]string
+	st.Require().NoError(
+		coalescingCache.
+			WithContextAbsentKeysLoader(func(loaderCtx context.Context, absentKeys ...string) (interface{}, error) {
+				deadline, ok := loaderCtx.Deadline()
+				st.Require().True(ok, "deadline expected in the loader context")
+				st.Require().Equal(expectedDeadline, deadline, "unexpected deadline")
+				return st.keysToMap(absentKeys...), nil
+			}).
+			Get(ctx, &dst, faker.RandomString(5)),
+		"No error expected",
+	)
+	st.Require().Len(dst, 1, "loaded key expected")
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestContextLoader_LastSetLoaderWins() {
+	key := faker.RandomString(5)
+	var dst string
+	st.Require().NoError(
+		st.cache.
+			WithContextAbsentKeysLoader(func(_ context.Context, absentKeys ...string) (interface{}, error) {
+				return "from context loader", nil
+			}).
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				return "from loader", nil
+			}).
+			Get(st.ctx, &dst, key),
+		"No error expected",
+	)
+	st.Require().Equal("from loader", dst, "the latest set loader must be used")
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestCoalesceLoads() {
+	const callers = 10
+	coalescingCache := cache.NewCache(cache.Options{
+		Redis:         st.client,
+		Marshaller:    st.marshaller,
+		CoalesceLoads: true,
+	})
+	keysToLoad := []string{faker.RandomString(5), faker.RandomString(5)}
+
+	var loaderCalls int32
+	release := make(chan struct{})
+	loader := coalescingCache.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		atomic.AddInt32(&loaderCalls, 1)
+		<-release
+		return st.keysToMap(absentKeys...), nil
+	})
+
+	var wg sync.WaitGroup
+	dsts := make([]map[string]string, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			errs[idx] = loader.Get(st.ctx, &dsts[idx], keysToLoad...)
+		}(i)
+	}
+	// give all the callers a chance to join the in-flight load
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	st.Require().EqualValues(1, atomic.LoadInt32(&loaderCalls), "only one loader call expected")
+	for i := 0; i < callers; i++ {
+		st.Require().NoError(errs[i], "No error expected for caller %d", i)
+		st.Require().EqualValues(st.keysToMap(keysToLoad...), dsts[i], "unexpected loaded items for caller %d", i)
+	}
+	st.checkElementsInCache(st.keysToMap(keysToLoad...))
+}
+
+type coalescedValue struct {
+	Name string
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestCoalescedCallersDontShareLoadedObjects() {
+	coalescingCache := cache.NewCache(cache.Options{
+		Redis:         st.client,
+		Marshaller:    st.marshaller,
+		CoalesceLoads: true,
+	})
+	key := faker.RandomString(7)
+	release := make(chan struct{})
+	loader := coalescingCache.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		<-release
+		return map[string]*coalescedValue{key: {Name: "loaded"}}, nil
+	})
+
+	var wg sync.WaitGroup
+	dsts := make([]map[string]*coalescedValue, 2)
+	errs := make([]error, 2)
+	for i := range dsts {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			errs[idx] = loader.Get(st.ctx, &dsts[idx], key)
+		}(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i := range dsts {
+		st.Require().NoError(errs[i], "No error expected for caller %d", i)
+		st.Require().Equal(map[string]*coalescedValue{key: {Name: "loaded"}}, dsts[i], "unexpected loaded items for caller %d", i)
+	}
+	st.Require().NotSame(dsts[0][key], dsts[1][key], "every caller must receive its own object")
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestCoalesceLoadsOnlyForTheSameLoader() {
+	coalescingCache := cache.NewCache(cache.Options{
+		Redis:         st.client,
+		Marshaller:    st.marshaller,
+		CoalesceLoads: true,
+	})
+	key := faker.RandomString(7)
+	release := make(chan struct{})
+	loaderFor := func(val string) *cache.Cache {
+		return coalescingCache.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+			<-release
+			return &cache.Item{Key: key, Value: val}, nil
+		})
+	}
+
+	var wg sync.WaitGroup
+	dsts := make([]string, 2)
+	errs := make([]error, 2)
+	for idx, c := range []*cache.Cache{loaderFor("first"), loaderFor("second")} {
+		wg.Add(1)
+		go func(idx int, c *cache.Cache) {
+			defer wg.Done()
+			errs[idx] = c.Get(st.ctx, &dsts[idx], key)
+		}(idx, c)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	st.Require().NoError(errs[0], "No error expected for the first loader")
+	st.Require().NoError(errs[1], "No error expected for the second loader")
+	st.Require().Equal([]string{"first", "second"}, dsts, "every caller must receive the items of its own loader")
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestCoalescedLoadOutlivesCancelledCaller() {
+	coalescingCache := cache.NewCache(cache.Options{
+		Redis:         st.client,
+		Marshaller:    st.marshaller,
+		CoalesceLoads: true,
+	})
+	key := faker.RandomString(7)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loadingCache := coalescingCache.WithContextAbsentKeysLoader(func(ctx context.Context, absentKeys ...string) (interface{}, error) {
+		close(started)
+		<-release
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return &cache.Item{Key: key, Value: "loaded"}, nil
+	})
+
+	leaderCtx, cancelLeader := context.WithCancel(st.ctx)
+	leaderErr := make(chan error, 1)
+	go func() {
+		var dst string
+		leaderErr <- loadingCache.Get(leaderCtx, &dst, key)
+	}()
+	<-started
+	waiterErr := make(chan error, 1)
+	var waiterDst string
+	go func() {
+		waiterErr <- loadingCache.Get(st.ctx, &waiterDst, key)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancelLeader()
+	st.Require().True(errors.Is(<-leaderErr, context.Canceled), "the cancelled caller must stop waiting")
+	close(release)
+
+	st.Require().NoError(<-waiterErr, "the waiter mustn't inherit the cancellation of the leader")
+	st.Require().Equal("loaded", waiterDst, "the loaded value expected")
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestLoaderPerKeyErrors() {
+	loadedKey := faker.RandomString(5)
+	failedKey := faker.RandomString(5)
+	failure := errors.New("load failed")
+
+	var dst map[string]string
+	loadErr := st.cache.
+		WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+			return cache.LoadResult{
+				Data:       st.keysToMap(loadedKey),
+				KeysToErrs: map[string]error{failedKey: failure},
+			}, nil
+		}).
+		Get(st.ctx, &dst, loadedKey, failedKey)
+
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(loadErr, &keyErr), "KeyErr expected, %+v given", loadErr)
+	st.Require().Len(keyErr.KeysToErrs, 1, "only the failed key error expected")
+	st.Require().True(errors.Is(keyErr.KeysToErrs[failedKey], failure), "the loader error expected")
+	st.Require().True(keyErr.HasNonCacheMissErrs(), "loader errors aren't cache misses")
+	st.Require().EqualValues(st.keysToMap(loadedKey), dst, "the loaded items expected")
+	st.checkElementsInCache(st.keysToMap(loadedKey))
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestViaGet_KeyOfAnotherType() {
+	hashKey := faker.RandomString(5)
+	st.Require().NoError(st.client.HSet(st.ctx, hashKey, "f", "v").Err(), "No error expected on setting the hash map")
+
+	var dst map[string]string
+	loadErr := st.cache.
+		WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+			st.Require().Fail("the key of another type mustn't be loaded")
+			return nil, nil
+		}).
+		Get(st.ctx, &dst, hashKey)
+
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(loadErr, &keyErr), "KeyErr expected, %+v given", loadErr)
+	st.Require().True(
+		strings.Contains(keyErr.KeysToErrs[hashKey].Error(), "WRONGTYPE"),
+		"WRONGTYPE expected for the hash map key, %+v given", keyErr,
+	)
+	st.Require().Equal(map[string]string{"f": "v"}, st.client.HGetAll(st.ctx, hashKey).Val(), "the hash map mustn't be overwritten")
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestViaHGetFieldsForKey_FieldWithSeparator() {
+	key := faker.RandomString(5)
+	field := "a/" + faker.RandomString(5)
+	var loaderCalls int32
+	c := st.cache.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		atomic.AddInt32(&loaderCalls, 1)
+		st.Require().Equal([]string{cachekeys.KeyWithField(key, field)}, absentKeys, "unexpected absent keys")
+		return []*cache.Item{{Key: key, Field: field, Value: "loaded"}}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		var dst map[string]map[string]string
+		st.Require().NoError(c.HGetFieldsForKey(st.ctx, &dst, key, field), "No error expected on getting")
+		st.Require().Equal(map[string]map[string]string{key: {field: "loaded"}}, dst, "the loaded field expected")
+	}
+	st.Require().EqualValues(1, atomic.LoadInt32(&loaderCalls), "the loaded field must be read from cache")
+}
+
+func (st *CacheAbsentKeysLoaderSuite) TestLoaderPerKeyErrors_HashFields() {
+	key := faker.RandomString(5)
+	loadedField := faker.RandomString(5)
+	failedField := faker.RandomString(5)
+	failure := errors.New("load failed")
+
+	var dst map[string]map[string]string
+	loadErr := st.cache.
+		WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+			return &cache.LoadResult{
+				Data: []*cache.Item{{Key: key, Field: loadedField, Value: "loaded"}},
+				KeysToErrs: map[string]error{
+					cachekeys.KeyWithField(key, failedField): failure,
+				},
+			}, nil
+		}).
+		HGetFieldsForKey(st.ctx, &dst, key, loadedField, failedField)
+
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(loadErr, &keyErr), "KeyErr expected, %+v given", loadErr)
+	st.Require().True(
+		errors.Is(keyErr.KeysToErrs[cachekeys.KeyWithField(key, failedField)], failure),
+		"the loader error expected",
+	)
+	st.Require().Equal(map[string]map[string]string{key: {loadedField: "loaded"}}, dst, "the loaded fields expected")
+
+	var cachedDst string
+	st.Require().NoError(st.cache.HGetFieldsForKey(st.ctx, &cachedDst, key, loadedField), "the loaded field must be cached")
+	st.Require().Equal("loaded", cachedDst, "unexpected cached field")
+}
+
 func TestCacheAbsentKeysLoaderSuite(t *testing.T) {
 	t.Parallel()
 	suite.Run(t, &CacheAbsentKeysLoaderSuite{})
@@ -0,0 +1,94 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+)
+
+type AtomicHashWritesSuite struct {
+	BaseCacheSuite
+	atomicCache *cache.Cache
+}
+
+func (st *AtomicHashWritesSuite) SetupTest() {
+	st.atomicCache = cache.NewCache(cache.Options{
+		Redis:            st.client,
+		Marshaller:       st.marshaller,
+		AtomicHashWrites: true,
+	})
+	// EVALSHA must fall back to EVAL for the scripts which aren't loaded
+	st.Require().NoError(st.client.ScriptFlush(st.ctx).Err(), "No error expected on flushing scripts")
+}
+
+func (st *AtomicHashWritesSuite) TestConditionalWrites() {
+	key, existingField, missingField := faker.RandomString(10), faker.RandomString(8), faker.RandomString(8)
+	st.Require().NoError(st.atomicCache.Set(st.ctx, &cache.Item{Key: key, Field: existingField, Value: "old"}))
+
+	results, err := st.atomicCache.SetWithResults(
+		st.ctx,
+		&cache.Item{Key: key, Field: existingField, Value: "new", IfExists: true},
+		&cache.Item{Key: key, Field: missingField, Value: "new", IfExists: true},
+		&cache.Item{Key: key, Field: existingField, Value: "newest", IfNotExists: true},
+	)
+	st.Require().NoError(err, "No error expected on setting")
+	outcomes := make([]cache.SetOutcome, 0, len(results))
+	for _, res := range results {
+		outcomes = append(outcomes, res.Outcome)
+	}
+	st.Require().Equal(
+		[]cache.SetOutcome{cache.SetWritten, cache.SetSkippedMissing, cache.SetSkippedExists},
+		outcomes,
+		"unexpected outcomes",
+	)
+
+	var dst map[string]map[string]string
+	st.Require().NoError(st.cache.HGetAll(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Equal(map[string]string{existingField: "new"}, dst[key], "only the existing field must be updated")
+	st.Require().Greater(int64(st.client.TTL(st.ctx, key).Val()), int64(0), "TTL must be set")
+}
+
+func (st *AtomicHashWritesSuite) TestIfExistsForHashesWithoutAtomicWritesOption() {
+	key, field := faker.RandomString(10), faker.RandomString(8)
+	results, err := st.cache.SetWithResults(st.ctx, &cache.Item{Key: key, Field: field, Value: "new", IfExists: true})
+	st.Require().NoError(err, "No error expected on setting")
+	st.Require().Equal(cache.SetSkippedMissing, results[0].Outcome, "the field mustn't be written")
+	st.Require().EqualValues(0, st.client.Exists(st.ctx, key).Val(), "the key mustn't be created")
+}
+
+func (st *AtomicHashWritesSuite) TestKeepLongerTTL() {
+	key := faker.RandomString(10)
+	st.Require().NoError(st.atomicCache.Set(st.ctx, &cache.Item{Key: key, Field: "f1", Value: "v", TTL: time.Hour}))
+
+	st.Require().NoError(st.atomicCache.Set(st.ctx, &cache.Item{Key: key, Field: "f2", Value: "v", TTL: time.Minute, KeepLongerTTL: true}))
+	st.Require().Greater(int64(st.client.TTL(st.ctx, key).Val()), int64(59*time.Minute), "the longer TTL must be kept")
+
+	st.Require().NoError(st.atomicCache.Set(st.ctx, &cache.Item{Key: key, Field: "f3", Value: "v", TTL: 2 * time.Hour, KeepLongerTTL: true}))
+	st.Require().Greater(int64(st.client.TTL(st.ctx, key).Val()), int64(time.Hour), "TTL must be extended")
+
+	st.Require().NoError(st.atomicCache.Set(st.ctx, &cache.Item{Key: key, Field: "f4", Value: "v", TTL: time.Minute}))
+	st.Require().LessOrEqual(int64(st.client.TTL(st.ctx, key).Val()), int64(time.Minute), "TTL must be replaced without KeepLongerTTL")
+
+	newKey := faker.RandomString(10)
+	st.Require().NoError(st.atomicCache.Set(st.ctx, &cache.Item{Key: newKey, Field: "f1", Value: "v", TTL: time.Minute, KeepLongerTTL: true}))
+	st.Require().Greater(int64(st.client.TTL(st.ctx, newKey).Val()), int64(0), "TTL must be set for a new key")
+}
+
+func (st *AtomicHashWritesSuite) TestHSetKV() {
+	key := faker.RandomString(10)
+	st.Require().NoError(st.atomicCache.HSetKV(st.ctx, key, "f1", "v1", "f2", "v2"), "No error expected on setting")
+
+	var dst map[string]map[string]string
+	st.Require().NoError(st.cache.HGetAll(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Equal(map[string]string{"f1": "v1", "f2": "v2"}, dst[key])
+	st.Require().Greater(int64(st.client.TTL(st.ctx, key).Val()), int64(0), "TTL must be set")
+}
+
+func TestAtomicHashWritesSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &AtomicHashWritesSuite{})
+}
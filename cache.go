@@ -4,11 +4,15 @@ import (
 	"context"
 	"time"
 
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
 	"github.com/vkuptcov/go-redis-cache/v8/internal"
+	"github.com/vkuptcov/go-redis-cache/v8/localcache"
 )
 
 type Cache struct {
 	opt Options
+	// isRoot is set for the caches created via NewCache, they own the state shared with the derived caches
+	isRoot bool
 }
 
 const DefaultDuration = 1 * time.Hour
@@ -21,10 +25,31 @@ func NewCache(opt Options) *Cache {
 	opt.DefaultTTL = cacheDuration
 
 	return &Cache{
-		opt: opt,
+		opt:    internal.InitOptions(opt),
+		isRoot: true,
 	}
 }
 
+// Close stops the background refreshes of stale values started by the cache
+// and by all the caches derived from it via With* methods.
+// The refreshes already queued are finished before Close returns.
+// It does nothing for the derived caches, as they share the refreshes with the cache created via NewCache
+func (cd *Cache) Close() {
+	if cd.isRoot {
+		internal.CloseOptions(cd.opt)
+	}
+}
+
+// SubscribeInvalidations keeps the local cache in sync with other instances:
+// it removes the keys published into the channel (see Options.InvalidationChannel) from the local cache.
+// The subscription is restored automatically after connection errors and the local cache is flushed then,
+// as the messages published in between are lost.
+// onErr is called for receive and decode errors and might be nil.
+// Close the returned subscriber or cancel ctx to stop listening.
+func SubscribeInvalidations(ctx context.Context, client Subscriber, channel string, local *localcache.Cache, onErr func(err error)) *InvalidationSubscriber {
+	return internal.SubscribeInvalidations(ctx, client, channel, local, onErr)
+}
+
 // WithTTL overrides the TTL which is set on Cache creation via cache.Options
 // If it is in the [0, 1s) than the value from cache.Options will be used
 // If it is less than 0, than cached values will be stored without an explicit TTL
@@ -41,7 +66,18 @@ func (cd *Cache) WithTTL(ttl time.Duration) *Cache {
 func (cd *Cache) WithAbsentKeysLoader(f func(absentKeys ...string) (interface{}, error)) *Cache {
 	opts := cd.opt
 	opts.AbsentKeysLoader = f
-	return &Cache{opt: opts}
+	opts.ContextAbsentKeysLoader = nil
+	return &Cache{opt: internal.WithNewLoadScope(opts)}
+}
+
+// WithContextAbsentKeysLoader does the same as WithAbsentKeysLoader,
+// but the loader receives the context passed to Get/HGetAll/HGetFieldsForKey/HGetKeysAndFields,
+// so it can respect deadlines, cancellation and other request-scoped values.
+func (cd *Cache) WithContextAbsentKeysLoader(f func(ctx context.Context, absentKeys ...string) (interface{}, error)) *Cache {
+	opts := cd.opt
+	opts.ContextAbsentKeysLoader = f
+	opts.AbsentKeysLoader = nil
+	return &Cache{opt: internal.WithNewLoadScope(opts)}
 }
 
 // ExtractCacheKeyWith sets a function which is used to transform loaded item
@@ -51,7 +87,7 @@ func (cd *Cache) WithAbsentKeysLoader(f func(absentKeys ...string) (interface{},
 func (cd *Cache) ExtractCacheKeyWith(f func(it interface{}) (key, field string)) *Cache {
 	opts := cd.opt
 	opts.CacheKeyExtractor = f
-	return &Cache{opt: opts}
+	return &Cache{opt: internal.WithNewLoadScope(opts)}
 }
 
 // TransformCacheKeyForDestination changes the data which is used to create a key for a destination map.
@@ -87,6 +123,13 @@ func (cd *Cache) Set(ctx context.Context, items ...*Item) error {
 	return internal.SetMulti(ctx, cd.opt, items...)
 }
 
+// SetWithResults does the same as Set, but returns the outcome for every item in the same order.
+// It allows to find out whether the items with IfExists or IfNotExists were written.
+// No results are returned if some of the items can't be marshalled: nothing is stored then
+func (cd *Cache) SetWithResults(ctx context.Context, items ...*Item) ([]SetResult, error) {
+	return internal.SetMultiWithResults(ctx, cd.opt, items...)
+}
+
 // SetKV sets multiple items in cache, default TTL or the TTL from WithTTL will be used
 func (cd *Cache) SetKV(ctx context.Context, keyValPairs ...interface{}) error {
 	return internal.SetKV(ctx, cd.opt, keyValPairs...)
@@ -122,6 +165,63 @@ func (cd *Cache) HGetKeysAndFields(ctx context.Context, dst interface{}, keysToF
 	return internal.HGetFields(ctx, cd.opt, dst, keysToFields)
 }
 
+// GetOrLoad does the same as Get, but the keys absent in cache are loaded via the loader.
+// The loader is used for this call only, so different call sites might share the same Cache
+func (cd *Cache) GetOrLoad(ctx context.Context, dst interface{}, loader Loader, keys ...string) error {
+	return internal.Get(ctx, internal.OptionsWithLoader(cd.opt, loader), dst, keys)
+}
+
+// HGetAllOrLoad does the same as HGetAll, but the keys absent in cache are loaded via the loader
+func (cd *Cache) HGetAllOrLoad(ctx context.Context, dst interface{}, loader Loader, keys ...string) error {
+	return internal.HGetAll(ctx, internal.OptionsWithLoader(cd.opt, loader), dst, keys)
+}
+
+// HGetFieldsOrLoad does the same as HGetFieldsForKey, but the fields absent in cache are loaded via the loader
+func (cd *Cache) HGetFieldsOrLoad(ctx context.Context, dst interface{}, loader Loader, key string, fields ...string) error {
+	return internal.HGetFields(ctx, internal.OptionsWithLoader(cd.opt, loader), dst, map[string][]string{key: fields})
+}
+
+// HGetKeysAndFieldsOrLoad does the same as HGetKeysAndFields, but the fields absent in cache are loaded via the loader
+func (cd *Cache) HGetKeysAndFieldsOrLoad(ctx context.Context, dst interface{}, loader Loader, keysToFields map[string][]string) error {
+	return internal.HGetFields(ctx, internal.OptionsWithLoader(cd.opt, loader), dst, keysToFields)
+}
+
 func (cd *Cache) Delete(ctx context.Context, keys ...string) error {
 	return internal.Delete(ctx, cd.opt, keys)
 }
+
+// DeleteByPattern deletes all the keys matching the Redis glob-style pattern (see SCAN MATCH).
+// The keys are found via SCAN on every master node and deleted via UNLINK in batches of Options.ScanBatchSize keys.
+// onProgress is optional and is called with the total stats after every batch.
+// The stats are returned even if the deletion is interrupted by an error or by ctx cancellation
+func (cd *Cache) DeleteByPattern(ctx context.Context, pattern string, onProgress func(stats DeleteByPatternStats)) (DeleteByPatternStats, error) {
+	return internal.DeleteByPattern(ctx, cd.opt, pattern, onProgress)
+}
+
+// DeleteByPrefix deletes all the keys created via cachekeys.CreateKey with the prefix, see DeleteByPattern
+func (cd *Cache) DeleteByPrefix(ctx context.Context, prefix string, onProgress func(stats DeleteByPatternStats)) (DeleteByPatternStats, error) {
+	return internal.DeleteByPattern(ctx, cd.opt, cachekeys.PrefixPattern(prefix), onProgress)
+}
+
+// HDelete deletes the fields from the Redis hash maps defined by the keys.
+// The keys without fields are ignored, use Delete to remove the whole keys
+func (cd *Cache) HDelete(ctx context.Context, keysToFields map[string][]string) error {
+	return internal.HDelete(ctx, cd.opt, keysToFields)
+}
+
+// DeleteKeysAndFields deletes the whole keys defined by KeyField without Field and the hash map fields,
+// e.g. KeyField{Key: "usr-by-dpmt|1", Field: "123"} deletes only the "123" field
+func (cd *Cache) DeleteKeysAndFields(ctx context.Context, keysAndFields ...KeyField) error {
+	return internal.DeleteKeysAndFields(ctx, cd.opt, keysAndFields)
+}
+
+// DeleteKeysAndFieldsStrings is the same as DeleteKeysAndFields for the strings created via cachekeys.KeyWithField,
+// e.g. "usr-by-dpmt|1/123" deletes only the "123" field and "usr|123" deletes the whole key.
+// The strings are split by the last field separator, so the keys containing it must be deleted via DeleteKeysAndFields
+func (cd *Cache) DeleteKeysAndFieldsStrings(ctx context.Context, keysAndFields ...string) error {
+	keyFields := make([]KeyField, len(keysAndFields))
+	for idx, s := range keysAndFields {
+		keyFields[idx].Key, keyFields[idx].Field = cachekeys.SplitKeyAndField(s)
+	}
+	return internal.DeleteKeysAndFields(ctx, cd.opt, keyFields)
+}
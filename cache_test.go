@@ -51,6 +51,14 @@ func (st *BaseCacheSuite) keyToElement(k string) string {
 	return k + "-element"
 }
 
+func (st *BaseCacheSuite) randomKeys(count int) []string {
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = faker.RandomString(8)
+	}
+	return keys
+}
+
 func (st *BaseCacheSuite) checkElementsInCache(expected map[string]string) {
 	st.T().Helper()
 	var keys []string
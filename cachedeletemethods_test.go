@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/suite"
 	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
 	"syreclabs.com/go/faker"
 )
 
@@ -84,6 +85,86 @@ func (st *DeleteMethodsSuite) TestDeleteNonExistsKeys() {
 	})
 }
 
+func (st *DeleteMethodsSuite) setHashFields(key string, fields ...string) {
+	st.T().Helper()
+	items := make([]*cache.Item, 0, len(fields))
+	for _, f := range fields {
+		items = append(items, &cache.Item{Key: key, Field: f, Value: st.keyToElement(f)})
+	}
+	st.Require().NoError(st.cache.Set(st.ctx, items...), "No error expected on setting %q", key)
+}
+
+func (st *DeleteMethodsSuite) hashFields(key string) map[string]string {
+	st.T().Helper()
+	var dst map[string]map[string]string
+	st.Require().NoError(st.cache.HGetAll(st.ctx, &dst, key), "No error expected on getting %q", key)
+	return dst[key]
+}
+
+func (st *DeleteMethodsSuite) TestHDelete() {
+	firstKey, secondKey := faker.RandomString(10), faker.RandomString(10)
+	st.setHashFields(firstKey, "f1", "f2", "f3")
+	st.setHashFields(secondKey, "f1", "f2")
+
+	st.Require().NoError(
+		st.cache.HDelete(st.ctx, map[string][]string{
+			firstKey:  {"f1", "f3"},
+			secondKey: {"f2", "non-existed-field"},
+		}),
+		"No error expected on delete",
+	)
+
+	st.Require().Equal(map[string]string{"f2": st.keyToElement("f2")}, st.hashFields(firstKey))
+	st.Require().Equal(map[string]string{"f1": st.keyToElement("f1")}, st.hashFields(secondKey))
+}
+
+func (st *DeleteMethodsSuite) TestDeleteKeysAndFields() {
+	plainKey, hashKey, wholeHashKey := faker.RandomString(10), faker.RandomString(10), faker.RandomString(10)
+	// the keys might contain the field separator
+	separatedKey := cachekeys.KeyWithField(faker.RandomString(10), "42")
+	for _, k := range []string{plainKey, separatedKey} {
+		st.Require().NoError(st.cache.SetKV(st.ctx, k, "val"), "No error expected on setting %q", k)
+	}
+	st.setHashFields(hashKey, "f1", "f2")
+	st.setHashFields(wholeHashKey, "f1", "f2")
+
+	st.Require().NoError(
+		st.cache.DeleteKeysAndFields(
+			st.ctx,
+			cache.KeyField{Key: plainKey},
+			cache.KeyField{Key: separatedKey},
+			cache.KeyField{Key: hashKey, Field: "f1"},
+			cache.KeyField{Key: wholeHashKey},
+			cache.KeyField{Key: wholeHashKey, Field: "f1"},
+		),
+		"No error expected on delete",
+	)
+
+	st.Require().EqualValues(0, st.client.Exists(st.ctx, plainKey, separatedKey, wholeHashKey).Val(), "the whole keys must be deleted")
+	st.Require().Equal(map[string]string{"f2": st.keyToElement("f2")}, st.hashFields(hashKey))
+}
+
+func (st *DeleteMethodsSuite) TestDeleteKeysAndFieldsStrings() {
+	plainKey, hashKey, wholeHashKey := faker.RandomString(10), faker.RandomString(10), faker.RandomString(10)
+	st.Require().NoError(st.cache.SetKV(st.ctx, plainKey, "val"), "No error expected on setting %q", plainKey)
+	st.setHashFields(hashKey, "f1", "f2")
+	st.setHashFields(wholeHashKey, "f1", "f2")
+
+	st.Require().NoError(
+		st.cache.DeleteKeysAndFieldsStrings(
+			st.ctx,
+			plainKey,
+			cachekeys.KeyWithField(hashKey, "f1"),
+			wholeHashKey,
+			cachekeys.KeyWithField(wholeHashKey, "f1"),
+		),
+		"No error expected on delete",
+	)
+
+	st.Require().EqualValues(0, st.client.Exists(st.ctx, plainKey, wholeHashKey).Val(), "the whole keys must be deleted")
+	st.Require().Equal(map[string]string{"f2": st.keyToElement("f2")}, st.hashFields(hashKey))
+}
+
 func TestDeleteMethodsSuite(t *testing.T) {
 	t.Parallel()
 	suite.Run(t, &DeleteMethodsSuite{})
@@ -172,6 +172,16 @@ func (st *GetMethodsSuite) TestHGetFieldsForKey() {
 	})
 }
 
+func (st *GetMethodsSuite) TestHGetFieldsWithFieldSeparator() {
+	key := faker.RandomString(5)
+	field := "a/" + faker.RandomString(5)
+	st.Require().NoError(st.cache.HSetKV(st.ctx, key, field, "val"), "No error expected on setting")
+
+	var dst map[string]map[string]string
+	st.Require().NoError(st.cache.HGetFieldsForKey(st.ctx, &dst, key, field), "No error expected on getting")
+	st.Require().Equal(map[string]map[string]string{key: {field: "val"}}, dst, "the field with a separator must be read as is")
+}
+
 func (st *GetMethodsSuite) TestLoadEmptyKeysForGetMethods() {
 	st.Run("empty keys for Get", func() {
 		var dst map[string]string
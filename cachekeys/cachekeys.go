@@ -46,6 +46,26 @@ func UnpackKey(key string, parts ...*string) {
 	UnpackKeyWithPrefix(key, prefixedSlice...)
 }
 
+// PrefixPattern returns the Redis glob-style pattern matching all the keys created via CreateKey with the prefix.
+// The pattern special characters of the prefix are escaped
+func PrefixPattern(prefix string) string {
+	return EscapePattern(prefix) + keysSeparator + "*"
+}
+
+// EscapePattern escapes the Redis glob-style pattern special characters, so the string is matched as is
+func EscapePattern(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 func KeyWithField(key, field string) string {
 	return key + fieldSeparator + field
 }
@@ -145,6 +145,22 @@ func TestSplitKeyAndField(t *testing.T) {
 	}
 }
 
+func TestPrefixPattern(t *testing.T) {
+	testCases := []struct {
+		testCase string
+		prefix   string
+		expected string
+	}{
+		{testCase: "plain prefix", prefix: "usr", expected: "usr|*"},
+		{testCase: "special characters are escaped", prefix: `u*s?r[1]\`, expected: `u\*s\?r\[1\]\\|*`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			requireLib.New(t).Equal(tc.expected, PrefixPattern(tc.prefix), "unexpected pattern")
+		})
+	}
+}
+
 func makeStringsAndPointers(length int) (strs []string, pointers []*string) {
 	strs = make([]string, length)
 	pointers = make([]*string, length)
@@ -156,3 +172,23 @@ func makeStringsAndPointers(length int) (strs []string, pointers []*string) {
 	}
 	return
 }
+
+func TestHashSlot(t *testing.T) {
+	testCases := []struct {
+		key      string
+		expected int
+		testCase string
+	}{
+		{key: "", expected: 0, testCase: "empty key"},
+		{key: "foo", expected: 12182, testCase: "plain key"},
+		{key: "123456789", expected: 12739, testCase: "CRC16 check value"},
+		{key: "{user1000}.following", expected: HashSlot("user1000"), testCase: "hash tag"},
+		{key: "foo{}{bar}", expected: HashSlot("foo{}{bar}"), testCase: "empty hash tag is ignored"},
+		{key: "foo{{bar}}zap", expected: HashSlot("{bar"), testCase: "the first closing brace ends the hash tag"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			requireLib.New(t).Equal(tc.expected, HashSlot(tc.key), "unexpected slot")
+		})
+	}
+}
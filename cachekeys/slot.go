@@ -0,0 +1,37 @@
+package cachekeys
+
+import "strings"
+
+// SlotsCount is the number of hash slots in Redis Cluster
+const SlotsCount = 16384
+
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// HashSlot returns the Redis Cluster hash slot of the key.
+// Only the hash tag is hashed if the key has one, e.g. "{user1}.following" and "{user1}.followers"
+// are in the same slot.
+func HashSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^key[i]]
+	}
+	return int(crc) % SlotsCount
+}
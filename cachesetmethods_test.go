@@ -188,6 +188,90 @@ func (st *SetMethodsSuite) TestHSetKV() {
 	}
 }
 
+func (st *SetMethodsSuite) TestSetWithResults() {
+	existingKey := faker.RandomString(10)
+	existingHashKey := faker.RandomString(10)
+	existingField := faker.RandomString(8)
+	st.Require().NoError(
+		st.cache.Set(
+			st.ctx,
+			&cache.Item{Key: existingKey, Value: "old"},
+			&cache.Item{Key: existingHashKey, Field: existingField, Value: "old"},
+		),
+		"No error expected on setting",
+	)
+
+	testCases := []struct {
+		testCase string
+		item     *cache.Item
+		expected cache.SetOutcome
+	}{
+		{
+			testCase: "plain item",
+			item:     &cache.Item{Key: faker.RandomString(10), Value: "new"},
+			expected: cache.SetWritten,
+		},
+		{
+			testCase: "if not exists for an absent key",
+			item:     &cache.Item{Key: faker.RandomString(10), Value: "new", IfNotExists: true},
+			expected: cache.SetWritten,
+		},
+		{
+			testCase: "if not exists for an existing key",
+			item:     &cache.Item{Key: existingKey, Value: "new", IfNotExists: true},
+			expected: cache.SetSkippedExists,
+		},
+		{
+			testCase: "if exists for an existing key",
+			item:     &cache.Item{Key: existingKey, Value: "new", IfExists: true},
+			expected: cache.SetWritten,
+		},
+		{
+			testCase: "if exists for an absent key",
+			item:     &cache.Item{Key: faker.RandomString(10), Value: "new", IfExists: true},
+			expected: cache.SetSkippedMissing,
+		},
+		{
+			testCase: "if not exists for an existing field",
+			item:     &cache.Item{Key: existingHashKey, Field: existingField, Value: "new", IfNotExists: true},
+			expected: cache.SetSkippedExists,
+		},
+		{
+			testCase: "if not exists for an absent field",
+			item:     &cache.Item{Key: existingHashKey, Field: faker.RandomString(8), Value: "new", IfNotExists: true},
+			expected: cache.SetWritten,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		st.Run(tc.testCase+" (single item)", func() {
+			results, err := st.cache.SetWithResults(st.ctx, tc.item)
+			st.Require().NoError(err, "No error expected on setting")
+			st.Require().Len(results, 1, "single result expected")
+			st.Require().Equal(tc.expected, results[0].Outcome, "unexpected outcome %s", results[0].Outcome)
+			st.Require().Same(tc.item, results[0].Item, "the result must refer to the item")
+		})
+	}
+
+	// all the items are pipelined together now, the keys written by IfNotExists items exist already
+	items := make([]*cache.Item, 0, len(testCases))
+	for _, tc := range testCases {
+		itemCopy := *tc.item
+		items = append(items, &itemCopy)
+	}
+	results, err := st.cache.SetWithResults(st.ctx, items...)
+	st.Require().NoError(err, "No error expected on setting")
+	st.Require().Len(results, len(items), "a result per item expected")
+	for idx, res := range results {
+		st.Require().Same(items[idx], res.Item, "results must be in the items order")
+		expected := testCases[idx].expected
+		if items[idx].IfNotExists {
+			expected = cache.SetSkippedExists
+		}
+		st.Require().Equal(expected, res.Outcome, "unexpected outcome %s for %q", res.Outcome, testCases[idx].testCase)
+	}
+}
+
 func TestSetMethodsSuite(t *testing.T) {
 	t.Parallel()
 	suite.Run(t, &SetMethodsSuite{})
@@ -0,0 +1,180 @@
+package cache_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+)
+
+// pipelinesRecorder records the number of commands in every executed pipeline
+// and the max number of the pipelines executed at once
+type pipelinesRecorder struct {
+	mu          sync.Mutex
+	sizes       []int
+	inFlight    int
+	maxInFlight int
+}
+
+func (r *pipelinesRecorder) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (r *pipelinesRecorder) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (r *pipelinesRecorder) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sizes = append(r.sizes, len(cmds))
+	r.inFlight++
+	if r.inFlight > r.maxInFlight {
+		r.maxInFlight = r.inFlight
+	}
+	return ctx, nil
+}
+
+func (r *pipelinesRecorder) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	// gives the concurrent pipelines a chance to overlap
+	time.Sleep(10 * time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight--
+	return nil
+}
+
+func (r *pipelinesRecorder) reset() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sizes := r.sizes
+	r.sizes = nil
+	return sizes
+}
+
+type ChunkedPipelinesSuite struct {
+	BaseCacheSuite
+	recorder     *pipelinesRecorder
+	chunkedCache *cache.Cache
+}
+
+const testMaxPipelineCommands = 3
+
+func (st *ChunkedPipelinesSuite) SetupTest() {
+	st.recorder = &pipelinesRecorder{}
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	client.AddHook(st.recorder)
+	st.chunkedCache = cache.NewCache(cache.Options{
+		Redis:               client,
+		Marshaller:          st.marshaller,
+		MaxPipelineCommands: testMaxPipelineCommands,
+		PipelineConcurrency: 2,
+	})
+}
+
+func (st *ChunkedPipelinesSuite) requirePipelineSizes(expected ...int) {
+	st.T().Helper()
+	sizes := st.recorder.reset()
+	st.Require().ElementsMatch(expected, sizes, "unexpected pipelines sizes")
+	for _, size := range sizes {
+		st.Require().LessOrEqual(size, testMaxPipelineCommands, "pipelines mustn't exceed MaxPipelineCommands")
+	}
+}
+
+func (st *ChunkedPipelinesSuite) TestSetAndGet() {
+	keys := st.randomKeys(7)
+	kvs := make([]interface{}, 0, 2*len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, k, st.keyToElement(k))
+	}
+	st.Require().NoError(st.chunkedCache.SetKV(st.ctx, kvs...), "No error expected on setting")
+	st.requirePipelineSizes(3, 3, 1)
+
+	var dst map[string]string
+	st.Require().NoError(st.chunkedCache.Get(st.ctx, &dst, keys...), "No error expected on getting")
+	st.Require().EqualValues(st.keysToMap(keys...), dst, "all the values from the chunks expected")
+	// the keys of every chunk are read via MGET
+	st.requirePipelineSizes(1, 1, 1)
+
+	st.Require().NoError(st.chunkedCache.Delete(st.ctx, keys...), "No error expected on deleting")
+	st.requirePipelineSizes(3, 3, 1)
+	st.Require().Zero(st.client.Exists(st.ctx, keys...).Val(), "all the keys must be deleted")
+}
+
+func (st *ChunkedPipelinesSuite) TestCacheMissErrorsFromChunksAreMerged() {
+	keys := st.randomKeys(5)
+	var dst []string
+	err := st.chunkedCache.AddCacheMissErrors().Get(st.ctx, &dst, keys...)
+	keyErr, ok := err.(*cache.KeyErr)
+	st.Require().True(ok, "KeyErr expected, %+v given", err)
+	st.Require().Len(keyErr.KeysToErrs, len(keys), "cache misses from all the chunks expected")
+	st.Require().Equal(len(keys), keyErr.CacheMissErrsCount, "unexpected cache miss errors count")
+}
+
+func (st *ChunkedPipelinesSuite) TestGetMixesHitsAndMisses() {
+	keys := st.randomKeys(5)
+	st.Require().NoError(st.cache.SetKV(st.ctx, keys[0], st.keyToElement(keys[0]), keys[3], st.keyToElement(keys[3])), "No error expected on setting")
+
+	var dst map[string]string
+	err := st.chunkedCache.AddCacheMissErrors().Get(st.ctx, &dst, keys...)
+	keyErr, ok := err.(*cache.KeyErr)
+	st.Require().True(ok, "KeyErr expected, %+v given", err)
+	st.Require().Equal(3, keyErr.CacheMissErrsCount, "unexpected cache miss errors count")
+	for _, k := range []string{keys[1], keys[2], keys[4]} {
+		st.Require().Contains(keyErr.KeysToErrs, k, "cache miss expected for the absent key")
+	}
+	st.Require().EqualValues(st.keysToMap(keys[0], keys[3]), dst, "the values must be mapped to their keys")
+}
+
+func (st *ChunkedPipelinesSuite) TestHashMapFields() {
+	key := faker.RandomString(8)
+	fields := st.randomKeys(5)
+	fieldVals := make([]interface{}, 0, 2*len(fields))
+	for _, f := range fields {
+		fieldVals = append(fieldVals, f, st.keyToElement(f))
+	}
+	st.Require().NoError(st.chunkedCache.HSetKV(st.ctx, key, fieldVals...), "No error expected on setting")
+	// every chunk is set via HSET of two fields and EXPIRE
+	st.requirePipelineSizes(2, 2, 2)
+
+	var dst map[string]string
+	st.Require().NoError(st.chunkedCache.HGetFieldsForKey(st.ctx, &dst, key, fields...), "No error expected on getting")
+	st.Require().Len(dst, len(fields), "all the fields from the chunks expected")
+	// the fields of every chunk are read via HMGET
+	st.requirePipelineSizes(1, 1)
+
+	items := make([]*cache.Item, 0, len(fields))
+	for _, f := range fields {
+		items = append(items, &cache.Item{Key: key, Field: f, Value: "updated"})
+	}
+	st.Require().NoError(st.chunkedCache.Set(st.ctx, items...), "No error expected on setting items")
+	// every item is set via HSET and EXPIRE
+	st.requirePipelineSizes(2, 2, 2, 2, 2)
+}
+
+func (st *ChunkedPipelinesSuite) TestChunksAreExecutedInOrder() {
+	key := faker.RandomString(8)
+	items := make([]*cache.Item, 0, 2*testMaxPipelineCommands+1)
+	for i := 0; i < cap(items); i++ {
+		items = append(items, &cache.Item{Key: key, Value: strconv.Itoa(i)})
+	}
+	st.Require().NoError(st.chunkedCache.Set(st.ctx, items...), "No error expected on setting")
+	st.requirePipelineSizes(3, 3, 1)
+	st.Require().Equal(1, st.recorder.maxInFlight, "the chunks of the same pipeline mustn't be executed concurrently")
+
+	var dst string
+	st.Require().NoError(st.cache.Get(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Equal(strconv.Itoa(len(items)-1), dst, "the last written value expected")
+}
+
+func TestChunkedPipelinesSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &ChunkedPipelinesSuite{})
+}
@@ -0,0 +1,181 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+var errNodeIsDown = errors.New("node is down")
+
+// twoNodesHook emulates a cluster of two nodes sharing the slots in halves over a single Redis.
+// The pipelines touching the slots of the second node fail if it's down.
+type twoNodesHook struct {
+	mu                sync.Mutex
+	secondNodeIsDown  bool
+	inFlight          int
+	maxInFlight       int
+	pipelinesExecuted int
+}
+
+func isOnSecondNode(key string) bool {
+	return cachekeys.HashSlot(key) >= cachekeys.SlotsCount/2
+}
+
+func (h *twoNodesHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *twoNodesHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *twoNodesHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	h.mu.Lock()
+	h.pipelinesExecuted++
+	h.inFlight++
+	if h.inFlight > h.maxInFlight {
+		h.maxInFlight = h.inFlight
+	}
+	isDown := h.secondNodeIsDown
+	h.mu.Unlock()
+	// let the concurrent pipelines overlap
+	time.Sleep(5 * time.Millisecond)
+	if isDown {
+		for _, cmd := range cmds {
+			if key, ok := cmd.Args()[1].(string); ok && isOnSecondNode(key) {
+				h.done()
+				return ctx, errNodeIsDown
+			}
+		}
+	}
+	return ctx, nil
+}
+
+func (h *twoNodesHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	h.done()
+	return nil
+}
+
+func (h *twoNodesHook) done() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.inFlight--
+}
+
+type ClusterPipelinesSuite struct {
+	BaseCacheSuite
+	hook         *twoNodesHook
+	clusterCache *cache.Cache
+}
+
+func (st *ClusterPipelinesSuite) SetupTest() {
+	st.hook = &twoNodesHook{}
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	client.AddHook(st.hook)
+	st.clusterCache = cache.NewCache(cache.Options{
+		Redis:                client,
+		Marshaller:           st.marshaller,
+		GroupPipelinesBySlot: true,
+		PipelineConcurrency:  2,
+	})
+}
+
+// keysOnNodes generates the keys for both nodes
+func keysOnNodes(perNode int) (firstNodeKeys, secondNodeKeys []string) {
+	for len(firstNodeKeys) < perNode || len(secondNodeKeys) < perNode {
+		k := faker.RandomString(10)
+		if isOnSecondNode(k) {
+			if len(secondNodeKeys) < perNode {
+				secondNodeKeys = append(secondNodeKeys, k)
+			}
+		} else if len(firstNodeKeys) < perNode {
+			firstNodeKeys = append(firstNodeKeys, k)
+		}
+	}
+	return firstNodeKeys, secondNodeKeys
+}
+
+func (st *ClusterPipelinesSuite) requireSecondNodeErrs(err error, secondNodeKeys []string) {
+	st.T().Helper()
+	var keysToErrs map[string]error
+	var keyErr *cache.KeyErr
+	var setErr *cache.SetErr
+	switch {
+	case errors.As(err, &keyErr):
+		keysToErrs = keyErr.KeysToErrs
+	case errors.As(err, &setErr):
+		keysToErrs = setErr.KeysToErrs
+	default:
+		st.Require().Fail("KeyErr or SetErr expected", "%+v given", err)
+	}
+	st.Require().Len(keysToErrs, len(secondNodeKeys), "only the second node keys must fail")
+	for _, k := range secondNodeKeys {
+		st.Require().True(errors.Is(keysToErrs[k], errNodeIsDown), "node error expected for %q", k)
+	}
+}
+
+func (st *ClusterPipelinesSuite) TestGetFailsOnlyForDownNodeKeys() {
+	firstNodeKeys, secondNodeKeys := keysOnNodes(3)
+	allKeys := append(append([]string{}, firstNodeKeys...), secondNodeKeys...)
+	st.Require().NoError(st.clusterCache.Set(st.ctx, st.items(allKeys)...), "No error expected on setting")
+
+	st.hook.secondNodeIsDown = true
+	var dst map[string]string
+	st.requireSecondNodeErrs(st.clusterCache.Get(st.ctx, &dst, allKeys...), secondNodeKeys)
+	st.Require().EqualValues(st.keysToMap(firstNodeKeys...), dst, "the first node keys must be read")
+}
+
+func (st *ClusterPipelinesSuite) TestSetFailsOnlyForDownNodeKeys() {
+	firstNodeKeys, secondNodeKeys := keysOnNodes(3)
+	allKeys := append(append([]string{}, firstNodeKeys...), secondNodeKeys...)
+
+	st.hook.secondNodeIsDown = true
+	st.requireSecondNodeErrs(st.clusterCache.Set(st.ctx, st.items(allKeys)...), secondNodeKeys)
+	st.checkElementsInCache(st.keysToMap(firstNodeKeys...))
+}
+
+func (st *ClusterPipelinesSuite) TestDeleteFailsOnlyForDownNodeKeys() {
+	firstNodeKeys, secondNodeKeys := keysOnNodes(3)
+	allKeys := append(append([]string{}, firstNodeKeys...), secondNodeKeys...)
+	st.Require().NoError(st.clusterCache.Set(st.ctx, st.items(allKeys)...), "No error expected on setting")
+
+	st.hook.secondNodeIsDown = true
+	st.requireSecondNodeErrs(st.clusterCache.Delete(st.ctx, allKeys...), secondNodeKeys)
+	st.Require().EqualValues(len(secondNodeKeys), st.client.Exists(st.ctx, allKeys...).Val(), "only the second node keys must be kept")
+}
+
+func (st *ClusterPipelinesSuite) TestPipelinesConcurrencyIsBounded() {
+	firstNodeKeys, secondNodeKeys := keysOnNodes(5)
+	allKeys := append(append([]string{}, firstNodeKeys...), secondNodeKeys...)
+	st.Require().NoError(st.clusterCache.Set(st.ctx, st.items(allKeys)...), "No error expected on setting")
+
+	slots := map[int]struct{}{}
+	for _, k := range allKeys {
+		slots[cachekeys.HashSlot(k)] = struct{}{}
+	}
+	st.Require().Equal(len(slots), st.hook.pipelinesExecuted, "a pipeline per slot expected")
+	st.Require().Equal(2, st.hook.maxInFlight, "pipelines concurrency must be bounded")
+}
+
+func (st *ClusterPipelinesSuite) items(keys []string) []*cache.Item {
+	items := make([]*cache.Item, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, &cache.Item{Key: k, Value: st.keyToElement(k)})
+	}
+	return items
+}
+
+func TestClusterPipelinesSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &ClusterPipelinesSuite{})
+}
@@ -0,0 +1,173 @@
+package cache_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+const (
+	firstNodeAddr  = "localhost:6379"
+	secondNodeAddr = "127.0.0.1:6379"
+)
+
+var errSlotsUnavailable = errors.New("slots are unavailable")
+
+// pipelinesKeysHook records the keys of every executed pipeline
+type pipelinesKeysHook struct {
+	mu            sync.Mutex
+	pipelinesKeys [][]string
+}
+
+func (h *pipelinesKeysHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *pipelinesKeysHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *pipelinesKeysHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	var keys []string
+	for _, cmd := range cmds {
+		keyArgs := cmd.Args()[1:2]
+		if cmd.Name() == "mget" {
+			keyArgs = cmd.Args()[1:]
+		}
+		for _, arg := range keyArgs {
+			key, _ := arg.(string)
+			keys = append(keys, key)
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pipelinesKeys = append(h.pipelinesKeys, keys)
+	return ctx, nil
+}
+
+func (h *pipelinesKeysHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func (h *pipelinesKeysHook) reset() [][]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pipelinesKeys := h.pipelinesKeys
+	h.pipelinesKeys = nil
+	return pipelinesKeys
+}
+
+// ClusterClientSuite runs the cache over *redis.ClusterClient with two nodes sharing the slots in halves.
+// Both nodes are served by the same Redis under different addresses
+type ClusterClientSuite struct {
+	BaseCacheSuite
+	// clusterHook records the pipelines executed by the cache
+	clusterHook *pipelinesKeysHook
+	// nodesHook records the pipelines sent to the nodes
+	nodesHook *pipelinesKeysHook
+}
+
+func (st *ClusterClientSuite) SetupTest() {
+	st.clusterHook = &pipelinesKeysHook{}
+	st.nodesHook = &pipelinesKeysHook{}
+}
+
+func (st *ClusterClientSuite) newClusterCache(slots func(ctx context.Context) ([]redis.ClusterSlot, error)) *cache.Cache {
+	clusterClient := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: slots,
+		NewClient: func(opt *redis.Options) *redis.Client {
+			node := redis.NewClient(opt)
+			node.AddHook(st.nodesHook)
+			return node
+		},
+	})
+	clusterClient.AddHook(st.clusterHook)
+	st.T().Cleanup(func() {
+		_ = clusterClient.Close()
+	})
+	return cache.NewCache(cache.Options{
+		Redis:      clusterClient,
+		Marshaller: st.marshaller,
+	})
+}
+
+func twoNodesSlots(context.Context) ([]redis.ClusterSlot, error) {
+	return []redis.ClusterSlot{
+		{Start: 0, End: cachekeys.SlotsCount/2 - 1, Nodes: []redis.ClusterNode{{Addr: firstNodeAddr}}},
+		{Start: cachekeys.SlotsCount / 2, End: cachekeys.SlotsCount - 1, Nodes: []redis.ClusterNode{{Addr: secondNodeAddr}}},
+	}, nil
+}
+
+func (st *ClusterClientSuite) TestPipelinesAreSplitByMasterNodes() {
+	clusterCache := st.newClusterCache(twoNodesSlots)
+	firstNodeKeys, secondNodeKeys := keysOnNodes(3)
+	allKeys := append(append([]string{}, firstNodeKeys...), secondNodeKeys...)
+	kvs := make([]interface{}, 0, 2*len(allKeys))
+	for _, k := range allKeys {
+		kvs = append(kvs, k, st.keyToElement(k))
+	}
+
+	st.Require().NoError(clusterCache.SetKV(st.ctx, kvs...), "No error expected on setting")
+	st.requireNodePipelines(firstNodeKeys, secondNodeKeys)
+
+	var dst map[string]string
+	st.Require().NoError(clusterCache.Get(st.ctx, &dst, allKeys...), "No error expected on getting")
+	st.Require().EqualValues(st.keysToMap(allKeys...), dst, "the values from both nodes expected")
+	st.requireNodePipelines(firstNodeKeys, secondNodeKeys)
+}
+
+// requireNodePipelines checks that there is a single pipeline for the keys of every node
+func (st *ClusterClientSuite) requireNodePipelines(firstNodeKeys, secondNodeKeys []string) {
+	st.T().Helper()
+	pipelinesKeys := st.clusterHook.reset()
+	st.Require().Len(pipelinesKeys, 2, "a pipeline per node expected")
+	if isOnSecondNode(pipelinesKeys[0][0]) {
+		pipelinesKeys[0], pipelinesKeys[1] = pipelinesKeys[1], pipelinesKeys[0]
+	}
+	st.Require().ElementsMatch(firstNodeKeys, pipelinesKeys[0], "unexpected first node keys")
+	st.Require().ElementsMatch(secondNodeKeys, pipelinesKeys[1], "unexpected second node keys")
+}
+
+func (st *ClusterClientSuite) TestNodeLookupErrorsAreReportedPerKey() {
+	clusterCache := st.newClusterCache(func(context.Context) ([]redis.ClusterSlot, error) {
+		return nil, errSlotsUnavailable
+	})
+	keys := st.randomKeys(3)
+
+	var dst map[string]string
+	getErr := clusterCache.Get(st.ctx, &dst, keys...)
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(getErr, &keyErr), "KeyErr expected, %+v given", getErr)
+	st.requireLookupErrs(keyErr.KeysToErrs, keys)
+
+	setErr := clusterCache.Set(st.ctx, &cache.Item{Key: keys[0], Value: "v0"}, &cache.Item{Key: keys[1], Value: "v1"})
+	var itemsErr *cache.SetErr
+	st.Require().True(errors.As(setErr, &itemsErr), "SetErr expected, %+v given", setErr)
+	st.requireLookupErrs(itemsErr.KeysToErrs, keys[:2])
+
+	st.Require().Empty(st.nodesHook.reset(), "nothing must be sent to the nodes")
+	st.Require().Empty(st.clusterHook.reset(), "the pipelines of the failed keys mustn't be executed")
+}
+
+func (st *ClusterClientSuite) requireLookupErrs(keysToErrs map[string]error, keys []string) {
+	st.T().Helper()
+	st.Require().Len(keysToErrs, len(keys), "every key must fail")
+	for _, k := range keys {
+		err := keysToErrs[k]
+		st.Require().True(errors.Is(err, errSlotsUnavailable), "slots error expected for %q, %+v given", k, err)
+		st.Require().True(strings.Contains(err.Error(), "cluster node lookup failed"), "lookup error expected for %q, %+v given", k, err)
+	}
+}
+
+func TestClusterClientSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &ClusterClientSuite{})
+}
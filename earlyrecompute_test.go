@@ -0,0 +1,111 @@
+package cache_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+type EarlyRecomputeSuite struct {
+	BaseCacheSuite
+}
+
+func (st *EarlyRecomputeSuite) newCache(beta float64) *cache.Cache {
+	return cache.NewCache(cache.Options{
+		Redis:              st.client,
+		Marshaller:         st.marshaller,
+		DefaultTTL:         time.Minute,
+		EarlyRecomputeBeta: beta,
+	})
+}
+
+func (st *EarlyRecomputeSuite) TestExpensiveValueIsRecomputedEarly() {
+	// with a huge beta every read of a value with non-zero compute duration is a recomputation
+	c := st.newCache(1e9)
+	key := faker.RandomString(7)
+	st.Require().NoError(
+		c.Set(st.ctx, &cache.Item{Key: key, Value: "old", ComputeDuration: time.Second}),
+		"No error expected on setting",
+	)
+
+	var loaderCalls int32
+	var dst string
+	st.Require().NoError(
+		c.
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				atomic.AddInt32(&loaderCalls, 1)
+				st.Require().Equal([]string{key}, absentKeys, "unexpected keys to recompute")
+				return &cache.Item{Key: key, Value: "new"}, nil
+			}).
+			Get(st.ctx, &dst, key),
+		"No error expected on getting",
+	)
+	st.Require().Equal("new", dst, "recomputed value expected")
+	st.Require().EqualValues(1, atomic.LoadInt32(&loaderCalls), "single recomputation expected")
+
+	var storedDst string
+	st.Require().NoError(c.Get(st.ctx, &storedDst, key), "No error expected on getting")
+	st.Require().Equal("new", storedDst, "recomputed value must be stored")
+}
+
+func (st *EarlyRecomputeSuite) TestLoadDurationIsRecorded() {
+	c := st.newCache(1e9)
+	key := faker.RandomString(7)
+	var loaderCalls int32
+	loadingCache := c.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		atomic.AddInt32(&loaderCalls, 1)
+		time.Sleep(time.Millisecond)
+		return &cache.Item{Key: key, Value: "val"}, nil
+	})
+	for i := 0; i < 2; i++ {
+		var dst string
+		st.Require().NoError(loadingCache.Get(st.ctx, &dst, key), "No error expected on getting")
+		st.Require().Equal("val", dst, "loaded value expected")
+	}
+	st.Require().EqualValues(2, atomic.LoadInt32(&loaderCalls), "the loaded value must be recomputed early")
+}
+
+func (st *EarlyRecomputeSuite) TestCheapValueIsNotRecomputed() {
+	c := st.newCache(1e9)
+	key := faker.RandomString(7)
+	field := faker.RandomString(4)
+	st.Require().NoError(
+		c.Set(st.ctx, &cache.Item{Key: key, Field: field, Value: "val"}),
+		"No error expected on setting",
+	)
+	var dst string
+	st.Require().NoError(
+		c.
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				st.Failf("no recomputation expected", "keys %v", absentKeys)
+				return nil, nil
+			}).
+			HGetFieldsForKey(st.ctx, &dst, key, field),
+		"No error expected on getting",
+	)
+	st.Require().Equal("val", dst, "stored value expected")
+}
+
+func (st *EarlyRecomputeSuite) TestValueIsReturnedWithoutLoader() {
+	c := st.newCache(1e9)
+	key := faker.RandomString(7)
+	field := faker.RandomString(4)
+	st.Require().NoError(
+		c.Set(st.ctx, &cache.Item{Key: key, Field: field, Value: "val", ComputeDuration: time.Second}),
+		"No error expected on setting",
+	)
+	var dst map[string]string
+	st.Require().NoError(c.HGetFieldsForKey(st.ctx, &dst, key, field), "No error expected on getting")
+	st.Require().Equal(map[string]string{cachekeys.KeyWithField(key, field): "val"}, dst, "stored value expected")
+}
+
+func TestEarlyRecomputeSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &EarlyRecomputeSuite{})
+}
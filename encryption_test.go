@@ -0,0 +1,66 @@
+package cache_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/marshallers"
+)
+
+type EncryptionSuite struct {
+	BaseCacheSuite
+}
+
+func (st *EncryptionSuite) encryptingCache(currentKeyID string, keys map[string][]byte) *cache.Cache {
+	marshaller, initErr := marshallers.NewEncryptingMarshaller(
+		marshallers.NewMarshaller(&marshallers.JSONMarshaller{}),
+		marshallers.EncryptionKeys{CurrentKeyID: currentKeyID, Keys: keys},
+	)
+	st.Require().NoError(initErr, "No init error expected")
+	return cache.NewCache(cache.Options{
+		Redis:      st.client,
+		Marshaller: marshaller,
+	})
+}
+
+func (st *EncryptionSuite) TestDecryptionErrorsAreReportedPerKey() {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	oldCache := st.encryptingCache("old", map[string][]byte{"old": oldKey})
+	newCache := st.encryptingCache("new", map[string][]byte{"new": newKey})
+
+	oldEncryptedKey, newEncryptedKey := faker.RandomString(10), faker.RandomString(10)
+	st.Require().NoError(oldCache.SetKV(st.ctx, oldEncryptedKey, "old-val"), "No error expected on setting")
+	st.Require().NoError(newCache.SetKV(st.ctx, newEncryptedKey, "new-val"), "No error expected on setting")
+
+	var dst map[string]string
+	getErr := newCache.Get(st.ctx, &dst, oldEncryptedKey, newEncryptedKey)
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(getErr, &keyErr), "KeyErr expected, %+v given", getErr)
+	st.Require().Len(keyErr.KeysToErrs, 1, "only the key encrypted with the unknown key must fail")
+	st.Require().True(
+		errors.Is(keyErr.KeysToErrs[oldEncryptedKey], marshallers.ErrDecryptionFailed),
+		"decryption error expected, %+v given", keyErr.KeysToErrs[oldEncryptedKey],
+	)
+	st.Require().Equal(map[string]string{newEncryptedKey: "new-val"}, dst, "only the decrypted value expected")
+
+	plainKey := faker.RandomString(10)
+	st.Require().NoError(st.cache.SetKV(st.ctx, plainKey, "plain-val"), "No error expected on setting")
+	singleDst := "untouched"
+	getErr = newCache.Get(st.ctx, &singleDst, plainKey)
+	st.Require().True(errors.As(getErr, &keyErr), "KeyErr expected, %+v given", getErr)
+	st.Require().True(
+		errors.Is(keyErr.KeysToErrs[plainKey], marshallers.ErrDecryptionFailed),
+		"decryption error expected for the not encrypted value, %+v given", keyErr.KeysToErrs[plainKey],
+	)
+	st.Require().Equal("untouched", singleDst, "dst mustn't be changed")
+}
+
+func TestEncryptionSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &EncryptionSuite{})
+}
@@ -10,5 +10,47 @@ type Options = internal.Options
 
 type KeyErr = internal.KeyErr
 
+type SetErr = internal.SetErr
+
+type LoadLeaseOptions = internal.LoadLeaseOptions
+
+type LoadResult = internal.LoadResult
+
+type KeyField = internal.KeyField
+
+type Loader = internal.Loader
+
+type SetResult = internal.SetResult
+
+type SetOutcome = internal.SetOutcome
+
+const (
+	SetWritten        = internal.SetWritten
+	SetSkippedExists  = internal.SetSkippedExists
+	SetSkippedMissing = internal.SetSkippedMissing
+	SetFailed         = internal.SetFailed
+)
+
+type DeleteByPatternStats = internal.DeleteByPatternStats
+
+type SchemaVersions = internal.SchemaVersions
+
+type Migration = internal.Migration
+
+// NewSchemaVersions creates an empty registry of the cached types versions, see Options.SchemaVersions
+func NewSchemaVersions() *SchemaVersions {
+	return internal.NewSchemaVersions()
+}
+
+type Publisher = internal.Publisher
+
+type Subscriber = internal.Subscriber
+
+type InvalidationSubscriber = internal.InvalidationSubscriber
+
 var ErrItemToCacheKeyFnRequired = internal.ErrItemToCacheKeyFnRequired
 var ErrCacheMiss = internal.ErrCacheMiss
+var ErrKnownAbsent = internal.ErrKnownAbsent
+var ErrRefreshQueueFull = internal.ErrRefreshQueueFull
+var ErrPublishNotSupported = internal.ErrPublishNotSupported
+var ErrNewerSchemaVersion = internal.ErrNewerSchemaVersion
@@ -0,0 +1,203 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+type GetOrLoadSuite struct {
+	BaseCacheSuite
+}
+
+type loadedUser struct {
+	ID   string
+	Name string
+}
+
+func (st *GetOrLoadSuite) TestGetOrLoad() {
+	cachedKey := faker.RandomString(7)
+	absentKey := faker.RandomString(7)
+	st.Require().NoError(st.cache.SetKV(st.ctx, cachedKey, st.keyToElement(cachedKey)), "No error expected on setting")
+
+	type ctxKey struct{}
+	ctx := context.WithValue(st.ctx, ctxKey{}, "call-ctx")
+	var dst map[string]string
+	st.Require().NoError(
+		st.cache.GetOrLoad(ctx, &dst, cache.Loader{
+			Load: func(ctx context.Context, absentKeys ...string) (interface{}, error) {
+				st.Require().Equal("call-ctx", ctx.Value(ctxKey{}), "the call context expected")
+				st.Require().Equal([]string{absentKey}, absentKeys, "only the absent key must be loaded")
+				return st.keysToMap(absentKeys...), nil
+			},
+		}, cachedKey, absentKey),
+		"No error expected on loading",
+	)
+	st.Require().EqualValues(st.keysToMap(cachedKey, absentKey), dst, "unexpected loaded values")
+	st.checkElementsInCache(st.keysToMap(cachedKey, absentKey))
+}
+
+func (st *GetOrLoadSuite) TestGetOrLoad_WithKeyExtractor() {
+	ids := []string{faker.RandomString(5), faker.RandomString(5)}
+	loader := cache.Loader{
+		Load: func(ctx context.Context, absentKeys ...string) (interface{}, error) {
+			users := make([]loadedUser, 0, len(absentKeys))
+			for _, k := range absentKeys {
+				users = append(users, loadedUser{ID: k, Name: "name-" + k})
+			}
+			return users, nil
+		},
+		CacheKeyExtractor: func(it interface{}) (key, field string) {
+			return it.(loadedUser).ID, ""
+		},
+	}
+	var dst map[string]loadedUser
+	st.Require().NoError(st.cache.GetOrLoad(st.ctx, &dst, loader, ids...), "No error expected on loading")
+	st.Require().Len(dst, len(ids), "all the users must be loaded")
+	for _, id := range ids {
+		st.Require().Equal(loadedUser{ID: id, Name: "name-" + id}, dst[id], "unexpected loaded user")
+	}
+
+	// the loader isn't kept in the cache
+	var cachedDst loadedUser
+	st.Require().NoError(st.cache.Get(st.ctx, &cachedDst, ids[0]), "the loaded user must be cached")
+	var absentDst loadedUser
+	st.Require().True(
+		errors.Is(st.cache.Get(st.ctx, &absentDst, faker.RandomString(5)), cache.ErrCacheMiss),
+		"cache miss expected without a loader",
+	)
+}
+
+func (st *GetOrLoadSuite) TestHGetAllOrLoad() {
+	key := faker.RandomString(7)
+	field := faker.RandomString(4)
+	var dst map[string]map[string]string
+	st.Require().NoError(
+		st.cache.HGetAllOrLoad(st.ctx, &dst, cache.Loader{
+			Load: func(ctx context.Context, absentKeys ...string) (interface{}, error) {
+				st.Require().Equal([]string{key}, absentKeys, "unexpected absent keys")
+				return []*cache.Item{{Key: key, Field: field, Value: "val"}}, nil
+			},
+		}, key),
+		"No error expected on loading",
+	)
+	st.Require().Equal(map[string]map[string]string{key: {field: "val"}}, dst, "unexpected loaded hash map")
+}
+
+func (st *GetOrLoadSuite) TestHGetFieldsOrLoad() {
+	key := faker.RandomString(7)
+	cachedField := faker.RandomString(4)
+	absentField := faker.RandomString(4)
+	st.Require().NoError(st.cache.HSetKV(st.ctx, key, cachedField, "cached"), "No error expected on setting")
+
+	loader := cache.Loader{
+		Load: func(ctx context.Context, absentKeys ...string) (interface{}, error) {
+			st.Require().Equal([]string{cachekeys.KeyWithField(key, absentField)}, absentKeys, "unexpected absent keys")
+			return "loaded", nil
+		},
+	}
+	var dst map[string]map[string]string
+	st.Require().NoError(
+		st.cache.HGetFieldsOrLoad(st.ctx, &dst, loader, key, cachedField, absentField),
+		"No error expected on loading",
+	)
+	st.Require().Equal(map[string]map[string]string{key: {cachedField: "cached", absentField: "loaded"}}, dst, "unexpected fields")
+
+	var keysAndFieldsDst map[string]map[string]string
+	st.Require().NoError(
+		st.cache.HGetKeysAndFieldsOrLoad(st.ctx, &keysAndFieldsDst, loader, map[string][]string{key: {cachedField, absentField}}),
+		"No error expected on loading",
+	)
+	st.Require().Equal(dst, keysAndFieldsDst, "the loaded field must be cached")
+}
+
+func (st *GetOrLoadSuite) TestConcurrentLoadersOfDifferentTypes() {
+	coalescingCache := cache.NewCache(cache.Options{
+		Redis:         st.client,
+		Marshaller:    st.marshaller,
+		CoalesceLoads: true,
+	})
+	key := faker.RandomString(7)
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var usersDst map[string]loadedUser
+	var namesDst map[string]string
+	var usersErr, namesErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		usersErr = coalescingCache.GetOrLoad(st.ctx, &usersDst, cache.Loader{
+			Load: func(ctx context.Context, absentKeys ...string) (interface{}, error) {
+				<-release
+				return &cache.Item{Key: key, Value: loadedUser{ID: key, Name: "user"}}, nil
+			},
+		}, key)
+	}()
+	go func() {
+		defer wg.Done()
+		namesErr = coalescingCache.GetOrLoad(st.ctx, &namesDst, cache.Loader{
+			Load: func(ctx context.Context, absentKeys ...string) (interface{}, error) {
+				<-release
+				return &cache.Item{Key: key, Value: "name"}, nil
+			},
+		}, key)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	st.Require().NoError(usersErr, "No error expected on loading the users")
+	st.Require().NoError(namesErr, "No error expected on loading the names")
+	st.Require().Equal(map[string]loadedUser{key: {ID: key, Name: "user"}}, usersDst, "the users loader items expected")
+	st.Require().Equal(map[string]string{key: "name"}, namesDst, "the names loader items expected")
+}
+
+func (st *GetOrLoadSuite) TestLoadersWithTheSameNameAreCoalesced() {
+	coalescingCache := cache.NewCache(cache.Options{
+		Redis:         st.client,
+		Marshaller:    st.marshaller,
+		CoalesceLoads: true,
+	})
+	key := faker.RandomString(7)
+	release := make(chan struct{})
+	var loaderCalls int32
+	loader := cache.Loader{
+		Name: "names",
+		Load: func(ctx context.Context, absentKeys ...string) (interface{}, error) {
+			atomic.AddInt32(&loaderCalls, 1)
+			<-release
+			return &cache.Item{Key: key, Value: "name"}, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	dsts := make([]string, 3)
+	for idx := range dsts {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			st.Require().NoError(coalescingCache.GetOrLoad(st.ctx, &dsts[idx], loader, key), "No error expected on loading")
+		}(idx)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	st.Require().EqualValues(1, atomic.LoadInt32(&loaderCalls), "only one loader call expected")
+	st.Require().Equal([]string{"name", "name", "name"}, dsts, "the shared items expected")
+}
+
+func TestGetOrLoadSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &GetOrLoadSuite{})
+}
@@ -0,0 +1,153 @@
+package cache_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+	"github.com/vkuptcov/go-redis-cache/v8/localcache"
+)
+
+type HashFieldTTLSuite struct {
+	BaseCacheSuite
+	fieldTTLCache *cache.Cache
+}
+
+// testFieldTTL is the least TTL which isn't replaced by the default one
+const testFieldTTL = time.Second
+
+func (st *HashFieldTTLSuite) SetupTest() {
+	st.fieldTTLCache = cache.NewCache(cache.Options{
+		Redis:        st.client,
+		Marshaller:   st.marshaller,
+		HashFieldTTL: true,
+	})
+}
+
+func (st *HashFieldTTLSuite) TestFieldsExpireOnTheirOwn() {
+	key := faker.RandomString(10)
+	st.Require().NoError(st.fieldTTLCache.Set(
+		st.ctx,
+		&cache.Item{Key: key, Field: "long", Value: "long-val", TTL: time.Hour},
+		&cache.Item{Key: key, Field: "short", Value: "short-val", TTL: testFieldTTL},
+	), "No error expected on setting")
+	st.Require().NoError(st.fieldTTLCache.Set(
+		st.ctx,
+		&cache.Item{Key: key, Field: "short-rewritten", Value: "val", TTL: testFieldTTL},
+	), "No error expected on setting")
+	// the key TTL depends on whether the server expires the fields on its own,
+	// it's checked for every mode in the internal package
+
+	time.Sleep(testFieldTTL)
+
+	var dst map[string]map[string]string
+	st.Require().NoError(st.fieldTTLCache.HGetAll(st.ctx, &dst, key), "No error expected on getting all the fields")
+	st.Require().Equal(map[string]string{"long": "long-val"}, dst[key], "only the not expired field expected")
+
+	var fieldsDst map[string]map[string]string
+	getErr := st.fieldTTLCache.AddCacheMissErrors().HGetFieldsForKey(st.ctx, &fieldsDst, key, "long", "short")
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(getErr, &keyErr), "KeyErr expected, %+v given", getErr)
+	st.Require().True(
+		errors.Is(keyErr.KeysToErrs[cachekeys.KeyWithField(key, "short")], cache.ErrCacheMiss),
+		"cache miss expected for the expired field, %+v given", keyErr,
+	)
+	st.Require().Equal(map[string]string{"long": "long-val"}, fieldsDst[key], "only the not expired field expected")
+}
+
+func (st *HashFieldTTLSuite) TestExpiredFieldsAreLoaded() {
+	key := faker.RandomString(10)
+	st.Require().NoError(st.fieldTTLCache.Set(st.ctx, &cache.Item{Key: key, Field: "f", Value: "old", TTL: testFieldTTL}))
+
+	time.Sleep(testFieldTTL)
+
+	var dst map[string]map[string]string
+	getErr := st.fieldTTLCache.HGetAllOrLoad(st.ctx, &dst, cache.Loader{
+		Load: func(_ context.Context, absentKeys ...string) (interface{}, error) {
+			return []*cache.Item{{Key: key, Field: "f", Value: "new"}}, nil
+		},
+	}, key)
+	st.Require().NoError(getErr, "No error expected on loading")
+	st.Require().Equal(map[string]string{"f": "new"}, dst[key], "the expired field must be reloaded")
+}
+
+func (st *HashFieldTTLSuite) TestExpiredFieldsAreNotServedLocally() {
+	local := localcache.New(localcache.Options{})
+	tieredCache := cache.NewCache(cache.Options{
+		Redis:        st.client,
+		Marshaller:   st.marshaller,
+		HashFieldTTL: true,
+		LocalCache:   local,
+	})
+	key := faker.RandomString(10)
+	st.Require().NoError(tieredCache.Set(st.ctx, &cache.Item{Key: key, Field: "f", Value: "old", TTL: testFieldTTL}))
+	var localDst map[string]map[string]string
+	st.Require().NoError(tieredCache.HGetFieldsForKey(st.ctx, &localDst, key, "f"), "No error expected on getting")
+	st.Require().Equal(map[string]string{"f": "old"}, localDst[key], "the field must be cached locally")
+	time.Sleep(testFieldTTL)
+	// the field is rewritten by another process bypassing the local tier
+	st.Require().NoError(st.fieldTTLCache.Set(st.ctx, &cache.Item{Key: key, Field: "f", Value: "new", TTL: time.Hour}))
+
+	var dst map[string]map[string]string
+	st.Require().NoError(tieredCache.HGetFieldsForKey(st.ctx, &dst, key, "f"), "No error expected on getting")
+	st.Require().Equal(map[string]string{"f": "new"}, dst[key], "the expired local field must be read from Redis")
+}
+
+func (st *HashFieldTTLSuite) TestServerIsProbedOnce() {
+	hook := &probesHook{probeErr: errors.New("connection reset")}
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	client.AddHook(hook)
+	fieldTTLCache := cache.NewCache(cache.Options{
+		Redis:        client,
+		Marshaller:   st.marshaller,
+		HashFieldTTL: true,
+	})
+	key := faker.RandomString(10)
+	for i := 0; i < 3; i++ {
+		st.Require().NoError(fieldTTLCache.Set(
+			st.ctx,
+			&cache.Item{Key: key, Field: "f1", Value: "v1", TTL: time.Hour},
+			&cache.Item{Key: key, Field: "f2", Value: "v2", TTL: time.Hour},
+		), "No error expected on setting")
+	}
+	st.Require().EqualValues(1, atomic.LoadInt32(&hook.probes), "the failed probe mustn't be repeated")
+}
+
+// probesHook fails the probes of the native hash map fields expiration
+type probesHook struct {
+	probeErr error
+	probes   int32
+}
+
+func (h *probesHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *probesHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *probesHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	if cmds[0].Name() == "command" {
+		atomic.AddInt32(&h.probes, 1)
+		return ctx, h.probeErr
+	}
+	return ctx, nil
+}
+
+func (h *probesHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestHashFieldTTLSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &HashFieldTTLSuite{})
+}
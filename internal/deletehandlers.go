@@ -2,21 +2,105 @@ package internal
 
 import (
 	"context"
+
+	"github.com/go-redis/redis/v8"
 )
 
+// deletion removes the whole key if there are no fields, otherwise only the hash map fields
+type deletion struct {
+	key    string
+	fields []string
+}
+
 func Delete(ctx context.Context, opts Options, keys []string) error {
-	switch len(keys) {
-	case 0:
+	return deleteKeysAndFields(ctx, opts, keys, nil)
+}
+
+// HDelete removes the hash map fields, the keys without fields are ignored
+func HDelete(ctx context.Context, opts Options, keysToFields map[string][]string) error {
+	return deleteKeysAndFields(ctx, opts, nil, keysToFields)
+}
+
+// KeyField defines the whole key if Field is empty, otherwise the hash map field of the key
+type KeyField struct {
+	Key   string
+	Field string
+}
+
+// DeleteKeysAndFields removes the whole keys and the hash map fields
+func DeleteKeysAndFields(ctx context.Context, opts Options, keysAndFields []KeyField) error {
+	var keys []string
+	keysToFields := map[string][]string{}
+	for _, kf := range keysAndFields {
+		if kf.Field == "" {
+			keys = append(keys, kf.Key)
+		} else {
+			keysToFields[kf.Key] = append(keysToFields[kf.Key], kf.Field)
+		}
+	}
+	return deleteKeysAndFields(ctx, opts, keys, keysToFields)
+}
+
+// deleteKeysAndFields removes the whole keys and the hash map fields.
+// The fields aren't removed separately if the whole key is removed
+func deleteKeysAndFields(ctx context.Context, opts Options, keys []string, keysToFields map[string][]string) error {
+	deletions := make([]deletion, 0, len(keys)+len(keysToFields))
+	wholeKeys := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if !wholeKeys[k] {
+			wholeKeys[k] = true
+			deletions = append(deletions, deletion{key: k})
+		}
+	}
+	for k, fields := range keysToFields {
+		if !wholeKeys[k] && len(fields) > 0 {
+			deletions = append(deletions, deletion{key: k, fields: fields})
+		}
+	}
+	if len(deletions) == 0 {
 		return nil
-	case 1:
-		cmd := opts.Redis.Del(ctx, keys[0])
-		return cmd.Err()
-	default:
-		pipeliner := opts.Redis.Pipeline()
-		for _, k := range keys {
-			pipeliner.Del(ctx, k)
+	}
+	var msg invalidationMessage
+	for _, d := range deletions {
+		if len(d.fields) == 0 {
+			opts.removeKeyLocally(d.key)
+			msg.addKeyAndField(d.key, "")
+		}
+		for _, f := range d.fields {
+			opts.removeLocally(d.key, f)
+			msg.addKeyAndField(d.key, f)
 		}
-		_, err := pipeliner.Exec(ctx)
-		return err
 	}
+	var delErr error
+	if len(deletions) == 1 && len(deletions[0].fields) == 0 {
+		delErr = opts.Redis.Del(ctx, deletions[0].key).Err()
+	} else {
+		cmds := execPipelined(
+			ctx,
+			opts,
+			deletions,
+			func(d deletion) string {
+				return d.key
+			},
+			func(pipeliner redis.Pipeliner, deletions []deletion) []redis.Cmder {
+				cmds := make([]redis.Cmder, 0, len(deletions))
+				for _, d := range deletions {
+					if len(d.fields) == 0 {
+						cmds = append(cmds, pipeliner.Del(ctx, d.key))
+					} else {
+						cmds = append(cmds, pipeliner.HDel(ctx, d.key, d.fields...))
+					}
+				}
+				return cmds
+			},
+		)
+		if keysErr := cmdsKeyErr(cmds); keysErr != nil {
+			delErr = keysErr
+		}
+	}
+	publishErr := publishInvalidation(ctx, opts, &msg)
+	if delErr != nil {
+		return delErr
+	}
+	return publishErr
 }
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// envelopeMagic starts every enveloped value.
+// Values are unwrapped only by the caches using the envelopes (see Options.usesEnvelopes),
+// such caches envelope the raw values starting with the magic too.
+const envelopeMagic = "\x00\xe5"
+
+const envelopeVersion byte = 1
+
+const (
+	envelopeHeaderLen = len(envelopeMagic) + 2
+	envelopeTimeLen   = 8
+	// envelopeSchemaVersionLen is the size of the uint32 schema version
+	envelopeSchemaVersionLen = 4
+)
+
+// envelope flags define which optional fields are stored in the envelope
+const (
+	envelopeHasSoftExpiry byte = 1 << iota
+	envelopeHasExpiry
+	envelopeIsTombstone
+	envelopeHasSchemaVersion
+)
+
+// envelope wraps a marshalled value with the metadata needed on reading
+type envelope struct {
+	// softExpiresAt is the time after which the value is returned but refreshed in the background
+	softExpiresAt time.Time
+
+	// expiresAt is the time the value expires in Redis
+	expiresAt time.Time
+	// computeDuration is how long it took to compute the value
+	computeDuration time.Duration
+
+	// tombstone marks the keys the absent keys loader couldn't find.
+	// Such envelopes have no payload and are valid until expiresAt
+	tombstone bool
+
+	// schemaVersion is the version of the payload type registered in SchemaVersions,
+	// zero means the value is stored without a version
+	schemaVersion uint32
+
+	payload []byte
+}
+
+func (e envelope) isSoftExpired(now time.Time) bool {
+	return !e.softExpiresAt.IsZero() && !now.Before(e.softExpiresAt)
+}
+
+func (e envelope) isExpired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// shouldRecomputeEarly implements the probabilistic early expiration (XFetch):
+// the value is recomputed before it actually expires with the probability
+// growing as the expiration approaches and with the cost of recomputation.
+// rnd must be in the (0, 1] range.
+func (e envelope) shouldRecomputeEarly(now time.Time, beta, rnd float64) bool {
+	if e.expiresAt.IsZero() || beta <= 0 {
+		return false
+	}
+	gap := time.Duration(-float64(e.computeDuration) * beta * math.Log(rnd))
+	return !now.Add(gap).Before(e.expiresAt)
+}
+
+func (e envelope) marshal() []byte {
+	var flags byte
+	size := envelopeHeaderLen + len(e.payload)
+	if !e.softExpiresAt.IsZero() {
+		flags |= envelopeHasSoftExpiry
+		size += envelopeTimeLen
+	}
+	if !e.expiresAt.IsZero() {
+		flags |= envelopeHasExpiry
+		size += 2 * envelopeTimeLen
+	}
+	if e.tombstone {
+		flags |= envelopeIsTombstone
+	}
+	if e.schemaVersion > 0 {
+		flags |= envelopeHasSchemaVersion
+		size += envelopeSchemaVersionLen
+	}
+	b := make([]byte, 0, size)
+	b = append(b, envelopeMagic...)
+	b = append(b, envelopeVersion, flags)
+	if flags&envelopeHasSoftExpiry != 0 {
+		b = appendTime(b, e.softExpiresAt)
+	}
+	if flags&envelopeHasExpiry != 0 {
+		b = appendTime(b, e.expiresAt)
+		b = appendUint64(b, uint64(e.computeDuration))
+	}
+	if flags&envelopeHasSchemaVersion != 0 {
+		var buf [envelopeSchemaVersionLen]byte
+		binary.BigEndian.PutUint32(buf[:], e.schemaVersion)
+		b = append(b, buf[:]...)
+	}
+	return append(b, e.payload...)
+}
+
+func hasEnvelopeMagic(data []byte) bool {
+	return len(data) >= len(envelopeMagic) && string(data[:len(envelopeMagic)]) == envelopeMagic
+}
+
+// unmarshalEnvelope extracts the envelope from the stored value.
+// ok is false if the value isn't enveloped
+func unmarshalEnvelope(data []byte) (e envelope, ok bool) {
+	if len(data) < envelopeHeaderLen || !hasEnvelopeMagic(data) {
+		return envelope{}, false
+	}
+	if data[len(envelopeMagic)] != envelopeVersion {
+		return envelope{}, false
+	}
+	flags := data[len(envelopeMagic)+1]
+	rest := data[envelopeHeaderLen:]
+	if flags&envelopeHasSoftExpiry != 0 {
+		if len(rest) < envelopeTimeLen {
+			return envelope{}, false
+		}
+		e.softExpiresAt, rest = readTime(rest)
+	}
+	if flags&envelopeHasExpiry != 0 {
+		if len(rest) < 2*envelopeTimeLen {
+			return envelope{}, false
+		}
+		e.expiresAt, rest = readTime(rest)
+		e.computeDuration = time.Duration(binary.BigEndian.Uint64(rest))
+		rest = rest[envelopeTimeLen:]
+	}
+	if flags&envelopeHasSchemaVersion != 0 {
+		if len(rest) < envelopeSchemaVersionLen {
+			return envelope{}, false
+		}
+		e.schemaVersion = binary.BigEndian.Uint32(rest)
+		rest = rest[envelopeSchemaVersionLen:]
+	}
+	e.tombstone = flags&envelopeIsTombstone != 0
+	e.payload = rest
+	return e, true
+}
+
+func appendTime(b []byte, t time.Time) []byte {
+	return appendUint64(b, uint64(t.UnixNano()))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [envelopeTimeLen]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func readTime(b []byte) (t time.Time, rest []byte) {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b))), b[envelopeTimeLen:]
+}
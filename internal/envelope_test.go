@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	requireLib "github.com/stretchr/testify/require"
+)
+
+func TestEnvelope_MarshalUnmarshal(t *testing.T) {
+	softExpiresAt := time.Unix(0, time.Now().UnixNano())
+	testCases := []struct {
+		testCase string
+		envelope envelope
+	}{
+		{
+			testCase: "envelope with soft expiry",
+			envelope: envelope{
+				softExpiresAt: softExpiresAt,
+				payload:       []byte(`{"Field":"value"}`),
+			},
+		},
+		{
+			testCase: "envelope with empty payload",
+			envelope: envelope{
+				softExpiresAt: softExpiresAt,
+				payload:       []byte{},
+			},
+		},
+		{
+			testCase: "envelope with expiry",
+			envelope: envelope{
+				softExpiresAt:   softExpiresAt,
+				expiresAt:       softExpiresAt.Add(time.Minute),
+				computeDuration: 150 * time.Millisecond,
+				payload:         []byte("value"),
+			},
+		},
+		{
+			testCase: "tombstone",
+			envelope: envelope{
+				expiresAt: softExpiresAt,
+				tombstone: true,
+				payload:   []byte{},
+			},
+		},
+		{
+			testCase: "envelope with schema version",
+			envelope: envelope{
+				expiresAt:     softExpiresAt,
+				schemaVersion: 3,
+				payload:       []byte(`{"Field":"value"}`),
+			},
+		},
+		{
+			testCase: "envelope without metadata",
+			envelope: envelope{
+				payload: []byte("value"),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			unmarshalled, ok := unmarshalEnvelope(tc.envelope.marshal())
+			require := requireLib.New(t)
+			require.True(ok, "envelope expected")
+			require.Equal(tc.envelope, unmarshalled, "unexpected envelope")
+		})
+	}
+}
+
+func TestEnvelope_NonEnvelopedValues(t *testing.T) {
+	for _, val := range []string{"", "value", `{"Field":"value"}`, "\x00", envelopeMagic, envelopeMagic + "\x02\x00"} {
+		_, ok := unmarshalEnvelope([]byte(val))
+		requireLib.New(t).Falsef(ok, "%q mustn't be treated as an envelope", val)
+	}
+}
+
+func TestEnvelope_IsSoftExpired(t *testing.T) {
+	now := time.Now()
+	require := requireLib.New(t)
+	require.False(envelope{}.isSoftExpired(now), "no soft expiry set")
+	require.False(envelope{softExpiresAt: now.Add(time.Second)}.isSoftExpired(now), "not expired yet")
+	require.True(envelope{softExpiresAt: now}.isSoftExpired(now), "expired")
+}
+
+func TestEnvelope_ShouldRecomputeEarly(t *testing.T) {
+	now := time.Now()
+	e := envelope{
+		expiresAt:       now.Add(time.Second),
+		computeDuration: 100 * time.Millisecond,
+	}
+	require := requireLib.New(t)
+	require.False(envelope{}.shouldRecomputeEarly(now, 1, 0.001), "no expiry set")
+	require.False(e.shouldRecomputeEarly(now, 0, 0.001), "early recomputation disabled")
+	// -ln(0.5) * 100ms ~ 69ms before the expiry
+	require.False(e.shouldRecomputeEarly(now, 1, 0.5), "far from the expiry")
+	require.True(e.shouldRecomputeEarly(now.Add(950*time.Millisecond), 1, 0.5), "close to the expiry")
+	// -ln(0.0001) * 100ms ~ 921ms before the expiry
+	require.True(e.shouldRecomputeEarly(now.Add(100*time.Millisecond), 1, 0.0001), "unlikely random value")
+	require.True(e.shouldRecomputeEarly(now.Add(time.Second), 1, 1), "expired")
+	require.False(envelope{expiresAt: now.Add(time.Second)}.shouldRecomputeEarly(now, 1000, 0.0001), "nothing to recompute for free values")
+}
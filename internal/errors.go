@@ -9,31 +9,66 @@ import (
 )
 
 var ErrCacheMiss = errors.New("cache: key is missing")
+var ErrKnownAbsent = errors.New("cache: key is known to be absent")
 var ErrWrongLoadFnType = errors.New("load function must return slice or key-value map")
 var ErrKeyPairs = errors.New("key-values pairs must be provided")
 var ErrNonStringKey = errors.New("string key expected")
+var ErrRefreshQueueFull = errors.New("background refresh queue is full")
+var ErrNewerSchemaVersion = errors.New("cache: value is stored with a newer schema version")
+var ErrPublishNotSupported = errors.New("Redis client must implement Publisher to publish into InvalidationChannel")
 var ErrItemToCacheKeyFnRequired = errors.New("CacheKeyExtractor transformation function must be set or only *Item's can be returned from the loader function")
 
 type KeyErr struct {
 	KeysToErrs         map[string]error
 	CacheMissErrsCount int
+	// KnownAbsentErrsCount is the number of keys the absent keys loader couldn't find previously
+	KnownAbsentErrsCount int
 }
 
 func (k *KeyErr) Error() string {
 	return fmt.Sprintf("Load keys err: %+v", k.KeysToErrs)
 }
 
+// HasNonCacheMissErrs checks whether there are errors other than ErrCacheMiss and ErrKnownAbsent
 func (k *KeyErr) HasNonCacheMissErrs() bool {
-	return len(k.KeysToErrs) > k.CacheMissErrsCount
+	return len(k.KeysToErrs) > k.CacheMissErrsCount+k.KnownAbsentErrsCount
+}
+
+// singleMissErr returns ErrCacheMiss or ErrKnownAbsent if it's the only error, otherwise the error itself
+func (k *KeyErr) singleMissErr() error {
+	if len(k.KeysToErrs) == 1 {
+		switch {
+		case k.CacheMissErrsCount == 1:
+			return ErrCacheMiss
+		case k.KnownAbsentErrsCount == 1:
+			return ErrKnownAbsent
+		}
+	}
+	return k
+}
+
+// withoutCacheMisses returns the error with the cache misses excluded or nil if there are only cache misses
+func (k *KeyErr) withoutCacheMisses() *KeyErr {
+	if len(k.KeysToErrs) == k.CacheMissErrsCount {
+		return nil
+	}
+	withoutMisses := &KeyErr{
+		KeysToErrs:           make(map[string]error, len(k.KeysToErrs)-k.CacheMissErrsCount),
+		KnownAbsentErrsCount: k.KnownAbsentErrsCount,
+	}
+	for key, err := range k.KeysToErrs {
+		if !errors.Is(err, ErrCacheMiss) {
+			withoutMisses.KeysToErrs[key] = err
+		}
+	}
+	return withoutMisses
 }
 
 func (k *KeyErr) AddErrorForKey(key string, err error) {
 	prevErr := k.KeysToErrs[key]
 	if prevErr == nil {
 		k.KeysToErrs[key] = errors.Wrapf(err, "Key %q load failed", key)
-		if errors.Is(err, ErrCacheMiss) {
-			k.CacheMissErrsCount++
-		}
+		k.countErr(err)
 	}
 }
 
@@ -42,8 +77,91 @@ func (k *KeyErr) AddErrorForKeyAndField(key, field string, err error) {
 	prevErr := k.KeysToErrs[keyWithField]
 	if prevErr == nil {
 		k.KeysToErrs[keyWithField] = errors.Wrapf(err, "Key %q with field %q load failed", key, field)
-		if errors.Is(err, ErrCacheMiss) {
-			k.CacheMissErrsCount++
+		k.countErr(err)
+	}
+}
+
+func (k *KeyErr) countErr(err error) {
+	switch {
+	case errors.Is(err, ErrCacheMiss):
+		k.CacheMissErrsCount++
+	case errors.Is(err, ErrKnownAbsent):
+		k.KnownAbsentErrsCount++
+	}
+}
+
+// mergeKeyErrs combines the errors into a new one, the errors of the first one take precedence.
+// Nil is returned if both errors are nil
+func mergeKeyErrs(first, second *KeyErr) *KeyErr {
+	if first == nil || second == nil {
+		if first == nil {
+			return second
 		}
+		return first
 	}
+	merged := &KeyErr{KeysToErrs: make(map[string]error, len(first.KeysToErrs)+len(second.KeysToErrs))}
+	for _, keyErr := range []*KeyErr{first, second} {
+		for k, err := range keyErr.KeysToErrs {
+			if _, ok := merged.KeysToErrs[k]; !ok {
+				merged.KeysToErrs[k] = err
+				merged.countErr(err)
+			}
+		}
+	}
+	return merged
+}
+
+// SetErr is returned if some of the items weren't stored or were stored without TTL
+type SetErr struct {
+	// KeysToErrs are the errors for the items which weren't stored.
+	// Hash map fields are added as keys created via cachekeys.KeyWithField
+	KeysToErrs map[string]error
+
+	// ExpireErrs are the errors for the keys which were written, but their TTL couldn't be set,
+	// so they might be stored without TTL
+	ExpireErrs map[string]error
+}
+
+func (s *SetErr) Error() string {
+	if len(s.ExpireErrs) == 0 {
+		return fmt.Sprintf("Set keys err: %+v", s.KeysToErrs)
+	}
+	return fmt.Sprintf("Set keys err: %+v, expire keys err: %+v", s.KeysToErrs, s.ExpireErrs)
+}
+
+// Is reports whether any of the per key errors matches the target,
+// so checks like errors.Is(err, context.DeadlineExceeded) work for the failed writes
+func (s *SetErr) Is(target error) bool {
+	for _, keysToErrs := range []map[string]error{s.KeysToErrs, s.ExpireErrs} {
+		for _, err := range keysToErrs {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *SetErr) addErrorForKeyAndField(key, field string, err error) {
+	if s.KeysToErrs == nil {
+		s.KeysToErrs = map[string]error{}
+	}
+	if field == "" {
+		s.KeysToErrs[key] = errors.Wrapf(err, "Key %q set failed", key)
+		return
+	}
+	s.KeysToErrs[cachekeys.KeyWithField(key, field)] = errors.Wrapf(err, "Key %q with field %q set failed", key, field)
+}
+
+func (s *SetErr) addExpireError(key string, err error) {
+	if s.ExpireErrs == nil {
+		s.ExpireErrs = map[string]error{}
+	}
+	if _, ok := s.ExpireErrs[key]; !ok {
+		s.ExpireErrs[key] = errors.Wrapf(err, "Key %q expire failed", key)
+	}
+}
+
+func (s *SetErr) isEmpty() bool {
+	return len(s.KeysToErrs) == 0 && len(s.ExpireErrs) == 0
 }
@@ -2,96 +2,362 @@ package internal
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
 
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
 	"github.com/vkuptcov/go-redis-cache/v8/internal/containers"
 )
 
+// keysReader defines how the keys and the hash map fields are read from Redis and from the local cache.
+// The keys are kept as KeyField pairs, so the fields containing the field separator are read as is
+type keysReader struct {
+	// fillPipeline adds the commands reading the given keys into the pipeline and returns them
+	fillPipeline func(ctx context.Context, opts Options, pipeliner redis.Pipeliner, keys []KeyField) []redis.Cmder
+
+	// readsLocally checks whether the keys can be read from the local cache
+	readsLocally bool
+
+	// tombstoneKey returns the key and the field the tombstone of the given key is stored in
+	tombstoneKey func(kf KeyField) KeyField
+
+	// errKey returns the key the errors are reported for in KeyErr, it's also passed to the absent keys loader.
+	// Hash map fields are reported as keys created via cachekeys.KeyWithField
+	errKey func(kf KeyField) string
+}
+
+// hashMapTombstoneField keeps the tombstones of the hash maps read entirely
+const hashMapTombstoneField = "\x00tombstone"
+
+var (
+	plainKeysReader = keysReader{
+		fillPipeline: readKeys,
+		readsLocally: true,
+		tombstoneKey: sameKeyField,
+		errKey:       redisKey,
+	}
+	hashMapsReader = keysReader{
+		fillPipeline: readHashMaps,
+		tombstoneKey: func(kf KeyField) KeyField {
+			return KeyField{Key: kf.Key, Field: hashMapTombstoneField}
+		},
+		errKey: redisKey,
+	}
+	hashFieldsReader = keysReader{
+		fillPipeline: readHashFields,
+		readsLocally: true,
+		tombstoneKey: sameKeyField,
+		errKey: func(kf KeyField) string {
+			return cachekeys.KeyWithField(kf.Key, kf.Field)
+		},
+	}
+)
+
+// errKeys returns the keys the errors of the given keys are reported for
+func (r keysReader) errKeys(keys []KeyField) []string {
+	errKeys := make([]string, len(keys))
+	for idx, kf := range keys {
+		errKeys[idx] = r.errKey(kf)
+	}
+	return errKeys
+}
+
+// keysWithErr returns the keys failed with the target error
+func (r keysReader) keysWithErr(keys []KeyField, keyErr *KeyErr, target error) []KeyField {
+	failed := make([]KeyField, 0, len(keyErr.KeysToErrs))
+	for _, kf := range keys {
+		if errors.Is(keyErr.KeysToErrs[r.errKey(kf)], target) {
+			failed = append(failed, kf)
+		}
+	}
+	return failed
+}
+
 func Get(ctx context.Context, opts Options, dst interface{}, keys []string) error {
 	if len(keys) == 0 {
 		return nil
 	}
-	return getInternal(ctx, opts, dst, func(pipeliner redis.Pipeliner) {
-		for _, k := range keys {
-			_ = pipeliner.Get(ctx, k)
-		}
-	})
+	return getInternal(ctx, opts, dst, plainKeys(keys), plainKeysReader)
 }
 
 func HGetAll(ctx context.Context, opts Options, dst interface{}, keys []string) error {
 	if len(keys) == 0 {
 		return nil
 	}
-	return getInternal(ctx, opts, dst, func(pipeliner redis.Pipeliner) {
-		for _, k := range keys {
-			pipeliner.HGetAll(ctx, k)
-		}
-	})
+	return getInternal(ctx, opts, dst, plainKeys(keys), hashMapsReader)
 }
 
 func HGetFields(ctx context.Context, opts Options, dst interface{}, keysToFields map[string][]string) error {
 	if len(keysToFields) == 0 {
 		return nil
 	}
-	return getInternal(ctx, opts, dst, func(pipeliner redis.Pipeliner) {
-		for key, fields := range keysToFields {
-			if len(fields) > 0 {
-				pipeliner.HMGet(ctx, key, fields...)
-			}
+	keysWithFields := make([]KeyField, 0, len(keysToFields))
+	for key, fields := range keysToFields {
+		for _, f := range fields {
+			keysWithFields = append(keysWithFields, KeyField{Key: key, Field: f})
+		}
+	}
+	if len(keysWithFields) == 0 {
+		return nil
+	}
+	return getInternal(ctx, opts, dst, keysWithFields, hashFieldsReader)
+}
+
+func plainKeys(keys []string) []KeyField {
+	keyFields := make([]KeyField, len(keys))
+	for idx, k := range keys {
+		keyFields[idx] = KeyField{Key: k}
+	}
+	return keyFields
+}
+
+func sameKey(k string) string {
+	return k
+}
+
+func sameKeyField(kf KeyField) KeyField {
+	return kf
+}
+
+func redisKey(kf KeyField) string {
+	return kf.Key
+}
+
+// readKeys reads the keys via MGET if all the keys of the command are known to be served by the same server:
+// there is a single MGET for a standalone client and an MGET per slot for clusters.
+// Otherwise, every key is read via its own GET.
+// MGET returns nil for the keys of other types as well, so such keys are read again via GET (see cmdsHandler.keysToRecheck)
+func readKeys(ctx context.Context, opts Options, pipeliner redis.Pipeliner, keys []KeyField) []redis.Cmder {
+	groupKey, ok := opts.multiKeyGroupKeyFn()
+	if !ok {
+		return getKeys(ctx, opts, pipeliner, keys)
+	}
+	groups := groupForPipelines(groupKey, keys, redisKey)
+	cmds := make([]redis.Cmder, 0, len(groups))
+	for _, group := range groups {
+		cmds = append(cmds, pipeliner.MGet(ctx, redisKeys(group)...))
+	}
+	return cmds
+}
+
+// getKeys reads every key via its own GET
+func getKeys(ctx context.Context, _ Options, pipeliner redis.Pipeliner, keys []KeyField) []redis.Cmder {
+	cmds := make([]redis.Cmder, 0, len(keys))
+	for _, kf := range keys {
+		cmds = append(cmds, pipeliner.Get(ctx, kf.Key))
+	}
+	return cmds
+}
+
+func redisKeys(keys []KeyField) []string {
+	redisKeys := make([]string, len(keys))
+	for idx, kf := range keys {
+		redisKeys[idx] = kf.Key
+	}
+	return redisKeys
+}
+
+func readHashMaps(ctx context.Context, _ Options, pipeliner redis.Pipeliner, keys []KeyField) []redis.Cmder {
+	cmds := make([]redis.Cmder, 0, len(keys))
+	for _, kf := range keys {
+		cmds = append(cmds, pipeliner.HGetAll(ctx, kf.Key))
+	}
+	return cmds
+}
+
+func readHashFields(ctx context.Context, _ Options, pipeliner redis.Pipeliner, keysWithFields []KeyField) []redis.Cmder {
+	keys := make([]string, 0, len(keysWithFields))
+	keysToFields := make(map[string][]string, len(keysWithFields))
+	for _, kf := range keysWithFields {
+		if _, ok := keysToFields[kf.Key]; !ok {
+			keys = append(keys, kf.Key)
 		}
-	})
+		keysToFields[kf.Key] = append(keysToFields[kf.Key], kf.Field)
+	}
+	cmds := make([]redis.Cmder, 0, len(keys))
+	for _, k := range keys {
+		cmds = append(cmds, pipeliner.HMGet(ctx, k, keysToFields[k]...))
+	}
+	return cmds
 }
 
-func getInternal(ctx context.Context, opts Options, dst interface{}, pipelinerFiller func(pipeliner redis.Pipeliner)) error {
-	loadErr := execAndAddIntoContainer(ctx, opts, dst, pipelinerFiller)
-	if loadErr != nil && opts.AbsentKeysLoader != nil {
+func getInternal(ctx context.Context, opts Options, dst interface{}, keys []KeyField, reader keysReader) error {
+	loadErr := execAndAddIntoContainer(ctx, opts, dst, keys, reader)
+	if loadErr != nil && opts.hasAbsentKeysLoader() {
 		var byKeyLoadErr *KeyErr
 		if errors.As(loadErr, &byKeyLoadErr) && !byKeyLoadErr.HasNonCacheMissErrs() {
-			absentKeys := make([]string, 0, byKeyLoadErr.CacheMissErrsCount)
-			for k := range byKeyLoadErr.KeysToErrs {
-				absentKeys = append(absentKeys, k)
+			// the keys known to be absent aren't loaded again, but still reported
+			keysErr := byKeyLoadErr.withoutCacheMisses()
+			if byKeyLoadErr.CacheMissErrsCount > 0 {
+				absentKeys := reader.keysWithErr(keys, byKeyLoadErr, ErrCacheMiss)
+				addErr := addAbsentKeys(ctx, opts, dst, reader, absentKeys)
+				var loaderKeysErr *KeyErr
+				if !errors.As(addErr, &loaderKeysErr) && addErr != nil {
+					return addErr
+				}
+				keysErr = mergeKeyErrs(loaderKeysErr, keysErr)
+			}
+			if keysErr == nil {
+				return nil
 			}
-			return addAbsentKeys(ctx, opts, dst, absentKeys...)
+			// the known absent key of a single element dst is reported the same way as without the loader
+			if container, containerErr := containers.NewContainer(dst); containerErr == nil && opts.returnsSingleMissErr(container) {
+				return keysErr.singleMissErr()
+			}
+			return keysErr
 		}
 	}
 	return loadErr
 }
 
-func addAbsentKeys(ctx context.Context, opts Options, dst interface{}, absentKeys ...string) error {
-	data, additionalErr := opts.AbsentKeysLoader(absentKeys...)
-	if additionalErr != nil {
-		return additionalErr
+func addAbsentKeys(ctx context.Context, opts Options, dst interface{}, reader keysReader, absentKeys []KeyField) error {
+	if opts.LoadLease.enabled() {
+		return addAbsentKeysUnderLease(ctx, opts, dst, reader, absentKeys)
 	}
-	if data == nil {
-		return nil
+	return loadAbsentKeysIntoContainer(ctx, opts, dst, reader, absentKeys)
+}
+
+func loadAbsentKeysIntoContainer(ctx context.Context, opts Options, dst interface{}, reader keysReader, absentKeys []KeyField) error {
+	load := func(ctx context.Context) ([]*Item, error) {
+		return loadAndCacheAbsentKeys(ctx, opts, reader, absentKeys)
 	}
-	dt := newDataTransformer(absentKeys, data, opts.CacheKeyExtractor)
-	items, transformErr := dt.getItems()
-	if transformErr != nil {
-		return transformErr
+	var items []*Item
+	var shared bool
+	var loadErr error
+	if opts.loadGroup != nil && opts.loadScope != "" {
+		items, shared, loadErr = opts.loadGroup.do(ctx, opts.loadScope, reader.errKeys(absentKeys), load)
+	} else {
+		items, loadErr = load(ctx)
+	}
+	if len(items) == 0 {
+		return loadErr
 	}
 	container, containerInitErr := containers.NewContainer(dst)
 	if containerInitErr != nil {
 		return containerInitErr
 	}
+	if !shared {
+		for _, it := range items {
+			addElementToContainer(opts, container, it.Key, it.Field, it.Value)
+		}
+		return loadErr
+	}
+	// the loaded values are unmarshalled for every caller sharing the load, so they don't share the same objects
+	decodeErr := &KeyErr{KeysToErrs: map[string]error{}}
 	for _, it := range items {
-		addElementToContainer(opts, container, it.Key, it.Field, it.Value)
+		if unmarshalErr := unmarshalLoadedItem(opts, container, it); unmarshalErr != nil {
+			decodeErr.AddErrorForKeyAndField(it.Key, it.Field, unmarshalErr)
+		}
+	}
+	if len(decodeErr.KeysToErrs) == 0 {
+		return loadErr
+	}
+	var loaderKeysErr *KeyErr
+	if loadErr != nil && !errors.As(loadErr, &loaderKeysErr) {
+		return loadErr
 	}
-	return SetMulti(ctx, opts, items...)
+	return mergeKeyErrs(loaderKeysErr, decodeErr)
 }
 
-func decodeAndAddElementToContainer(opts Options, container containers.Container, key, subkey, marshalledVal string) error {
+// unmarshalLoadedItem adds a new element unmarshalled from the stored value of the loaded item into the container
+func unmarshalLoadedItem(opts Options, container containers.Container, it *Item) error {
+	payload := it.marshalled
+	if e, ok := opts.unwrap(payload); ok {
+		payload = e.payload
+	}
 	dstEl := container.DstEl()
-	unmarshalErr := opts.Marshaller.Unmarshal([]byte(marshalledVal), dstEl)
-	if unmarshalErr != nil {
+	if unmarshalErr := opts.Marshaller.Unmarshal(payload, dstEl); unmarshalErr != nil {
 		return unmarshalErr
 	}
-	addElementToContainer(opts, container, key, subkey, dstEl)
+	addElementToContainer(opts, container, it.Key, it.Field, dstEl)
 	return nil
 }
 
+// loadAndCacheAbsentKeys calls the loader for the absent keys and stores the loaded items in cache.
+// The loaded items are returned with their marshalled values even if they weren't stored
+// or the loader failed for some of the keys: such failures are returned as KeyErr.
+// Tombstones are stored for the keys the loader didn't return if the negative caching is enabled.
+func loadAndCacheAbsentKeys(ctx context.Context, opts Options, reader keysReader, absentKeys []KeyField) ([]*Item, error) {
+	loaderKeys := reader.errKeys(absentKeys)
+	startedAt := time.Now()
+	data, additionalErr := opts.loadAbsentKeys(ctx, loaderKeys)
+	computeDuration := time.Since(startedAt)
+	if additionalErr != nil {
+		return nil, additionalErr
+	}
+	data, loaderKeysErr := splitLoadResult(data)
+	var items []*Item
+	if data != nil {
+		dt := newDataTransformer(loaderKeys, data, opts.CacheKeyExtractor)
+		var transformErr error
+		items, transformErr = dt.getItems()
+		if transformErr != nil {
+			return nil, transformErr
+		}
+	}
+	// the items are copied as the loader might keep them
+	for idx, it := range items {
+		loaded := *it
+		if loaded.ComputeDuration == 0 {
+			loaded.ComputeDuration = computeDuration
+		}
+		items[idx] = &loaded
+	}
+	tombstones := tombstonesForNotLoaded(opts, reader, absentKeys, items, loaderKeysErr)
+	itemsToCache := items
+	if len(tombstones) > 0 {
+		itemsToCache = append(append(make([]*Item, 0, len(items)+len(tombstones)), items...), tombstones...)
+	}
+	if len(itemsToCache) > 0 {
+		marshalled, marshalErr := marshalItems(opts, itemsToCache)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+		for idx, it := range items {
+			it.marshalled = marshalled[idx]
+		}
+		if _, setErr := setMarshalled(ctx, opts, true, itemsToCache, marshalled); setErr != nil {
+			return items, setErr
+		}
+	}
+	if loaderKeysErr != nil {
+		return items, loaderKeysErr
+	}
+	return items, nil
+}
+
+// tombstonesForNotLoaded creates the tombstones for the absent keys which weren't loaded,
+// the keys the loader failed for aren't known to be absent
+func tombstonesForNotLoaded(opts Options, reader keysReader, absentKeys []KeyField, loaded []*Item, loaderKeysErr *KeyErr) []*Item {
+	if opts.NegativeTTL <= 0 {
+		return nil
+	}
+	loadedKeys := make(map[KeyField]struct{}, 2*len(loaded))
+	for _, it := range loaded {
+		loadedKeys[KeyField{Key: it.Key}] = struct{}{}
+		loadedKeys[KeyField{Key: it.Key, Field: it.Field}] = struct{}{}
+	}
+	var tombstones []*Item
+	for _, kf := range absentKeys {
+		if _, ok := loadedKeys[kf]; ok {
+			continue
+		}
+		if loaderKeysErr != nil && loaderKeysErr.KeysToErrs[reader.errKey(kf)] != nil {
+			continue
+		}
+		tombstoneKey := reader.tombstoneKey(kf)
+		tombstones = append(tombstones, &Item{
+			Key:       tombstoneKey.Key,
+			Field:     tombstoneKey.Field,
+			TTL:       opts.NegativeTTL,
+			tombstone: true,
+		})
+	}
+	return tombstones
+}
+
 func addElementToContainer(opts Options, container containers.Container, key, subkey string, val interface{}) {
 	var skip bool
 	if opts.TransformCacheKeyForDestination != nil {
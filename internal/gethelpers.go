@@ -2,6 +2,9 @@ package internal
 
 import (
 	"context"
+	"math/rand"
+	"reflect"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
@@ -9,119 +12,333 @@ import (
 	"github.com/vkuptcov/go-redis-cache/v8/internal/containers"
 )
 
-func execAndAddIntoContainer(ctx context.Context, opts Options, dst interface{}, pipelinerFiller func(pipeliner redis.Pipeliner)) error {
-	if opts.AbsentKeysLoader != nil {
+func execAndAddIntoContainer(ctx context.Context, opts Options, dst interface{}, keys []KeyField, reader keysReader) error {
+	if opts.hasAbsentKeysLoader() {
 		opts.AddCacheMissErrors = true
 	}
-	pipeliner := opts.Redis.Pipeline()
-	pipelinerFiller(pipeliner)
-
-	// pipeliner errs will be checked for all the keys
-	cmds, _ := pipeliner.Exec(ctx)
-
 	container, containerInitErr := containers.NewContainer(dst)
 	if containerInitErr != nil {
 		return containerInitErr
 	}
-	container.InitWithSize(len(cmds))
-	isSingleElementContainer := !container.IsMultiElementContainer()
-	// in case we don't have the desired element in cache and we want just to load a single one,
-	// it's convenient to return a single cache miss error instead of KeyErr because the key is already known
-	returnErrCacheMiss := isSingleElementContainer &&
-		!opts.DisableCacheMissErrorsForSingleElementDst &&
-		!opts.AddCacheMissErrors
-	if returnErrCacheMiss || opts.AbsentKeysLoader != nil {
+	container.InitWithSize(len(keys))
+
+	returnErrCacheMiss := opts.returnsSingleMissErr(container)
+	if returnErrCacheMiss || opts.hasAbsentKeysLoader() {
 		opts.AddCacheMissErrors = true
 	}
 
-	byKeysErr := handleCmds(opts, cmds, container)
+	h := newCmdsHandler(opts, container, reader)
+	keysToRead := keys
+	if opts.LocalCache != nil && reader.readsLocally {
+		keysToRead = h.addLocallyCached(keys)
+	}
+
+	var cmds []redis.Cmder
+	if len(keysToRead) > 0 {
+		// pipeliner errs will be checked for all the keys
+		cmds = execPipelined(ctx, opts, keysToRead, redisKey, func(pipeliner redis.Pipeliner, keys []KeyField) []redis.Cmder {
+			return reader.fillPipeline(ctx, opts, pipeliner, keys)
+		})
+	}
+
+	h.handleCmds(cmds)
+	if len(h.keysToRecheck) > 0 {
+		// the keys which MGET returned nil for are either absent or not strings, GET tells them apart
+		recheckCmds := execPipelined(ctx, opts, h.keysToRecheck, redisKey, func(pipeliner redis.Pipeliner, keys []KeyField) []redis.Cmder {
+			return getKeys(ctx, opts, pipeliner, keys)
+		})
+		h.handleCmds(recheckCmds)
+	}
+	if len(h.keysToRefresh) > 0 {
+		refreshInBackground(ctx, opts, reader, h.keysToRefresh)
+	}
+	byKeysErr := h.byKeysErr
 
 	if len(byKeysErr.KeysToErrs) > 0 {
-		if returnErrCacheMiss && len(byKeysErr.KeysToErrs) == 1 && byKeysErr.CacheMissErrsCount == 1 {
-			return ErrCacheMiss
+		if returnErrCacheMiss {
+			return byKeysErr.singleMissErr()
 		}
 		return byKeysErr
 	}
 	return nil
 }
 
-func handleCmds(opts Options, cmds []redis.Cmder, container containers.Container) (byKeysErr *KeyErr) {
-	byKeysErr = &KeyErr{
-		KeysToErrs:         map[string]error{},
-		CacheMissErrsCount: 0,
+// returnsSingleMissErr checks whether ErrCacheMiss or ErrKnownAbsent is returned instead of KeyErr.
+// In case we don't have the desired element in cache and we want just to load a single one,
+// it's convenient to return a single cache miss error instead of KeyErr because the key is already known
+func (opt Options) returnsSingleMissErr(container containers.Container) bool {
+	return !container.IsMultiElementContainer() &&
+		!opt.DisableCacheMissErrorsForSingleElementDst &&
+		!opt.AddCacheMissErrors
+}
+
+// cmdsHandler adds the values read from Redis or from the local cache into the container
+// and collects the per key errors and the keys to be refreshed in the background
+type cmdsHandler struct {
+	opts      Options
+	container containers.Container
+	reader    keysReader
+	now       time.Time
+	byKeysErr *KeyErr
+	// keysToRefresh are returned from cache, but are stale and need to be reloaded
+	keysToRefresh []KeyField
+	// keysToRecheck are returned from MGET as nil, they are either absent or hold not strings
+	keysToRecheck []KeyField
+}
+
+func newCmdsHandler(opts Options, container containers.Container, reader keysReader) *cmdsHandler {
+	return &cmdsHandler{
+		opts:      opts,
+		container: container,
+		reader:    reader,
+		now:       time.Now(),
+		byKeysErr: &KeyErr{
+			KeysToErrs:         map[string]error{},
+			CacheMissErrsCount: 0,
+		},
 	}
+}
+
+func (h *cmdsHandler) handleCmds(cmds []redis.Cmder) {
 	for _, cmderr := range cmds {
 		key := cmderr.Args()[1].(string)
 		if cmderr.Err() != nil {
-			if errors.Is(cmderr.Err(), redis.Nil) {
-				if opts.AddCacheMissErrors {
-					byKeysErr.AddErrorForKey(key, ErrCacheMiss)
+			for _, k := range cmdKeys(cmderr) {
+				if errors.Is(cmderr.Err(), redis.Nil) {
+					if h.opts.AddCacheMissErrors {
+						h.byKeysErr.AddErrorForKey(k, ErrCacheMiss)
+					}
+				} else {
+					h.byKeysErr.AddErrorForKey(k, cmderr.Err())
 				}
-			} else {
-				byKeysErr.AddErrorForKey(key, cmderr.Err())
 			}
 			continue
 		}
 
 		switch typedCmd := cmderr.(type) {
-		// returned for HMGET
+		// returned for HMGET and MGET
 		case *redis.SliceCmd:
-			handleSliceCmd(opts, typedCmd, container, key, byKeysErr)
+			if typedCmd.Name() == "mget" {
+				h.handleMGetCmd(typedCmd)
+			} else {
+				h.handleSliceCmd(typedCmd, key)
+			}
 		// returned for HGETALL
 		case *redis.StringStringMapCmd:
-			handleStringStringMapCmd(opts, typedCmd, container, key, byKeysErr)
+			h.handleStringStringMapCmd(typedCmd, key)
+		// returned for GET
 		case *redis.StringCmd:
-			handleStringCmd(opts, typedCmd, container, key, byKeysErr)
+			h.handleStringCmd(typedCmd, key)
 		}
 	}
-	return byKeysErr
 }
 
-func handleSliceCmd(opts Options, typedCmd *redis.SliceCmd, container containers.Container, key string, byKeysErr *KeyErr) {
+func (h *cmdsHandler) handleSliceCmd(typedCmd *redis.SliceCmd, key string) {
 	fields := typedCmd.Args()[2:]
 	for fieldIdx, val := range typedCmd.Val() {
 		field := fields[fieldIdx].(string)
 		switch t := val.(type) {
 		case error:
 			if errors.Is(t, redis.Nil) {
-				if opts.AddCacheMissErrors {
-					byKeysErr.AddErrorForKeyAndField(key, field, ErrCacheMiss)
+				if h.opts.AddCacheMissErrors {
+					h.byKeysErr.AddErrorForKeyAndField(key, field, ErrCacheMiss)
 				}
 			} else {
-				byKeysErr.AddErrorForKeyAndField(key, field, t)
+				h.byKeysErr.AddErrorForKeyAndField(key, field, t)
 			}
 		case string:
-			if decodeErr := decodeAndAddElementToContainer(opts, container, key, field, t); decodeErr != nil {
-				byKeysErr.AddErrorForKeyAndField(key, field, decodeErr)
+			if added, decodeErr := h.decodeAndAdd(key, field, KeyField{Key: key, Field: field}, t); decodeErr != nil {
+				h.byKeysErr.AddErrorForKeyAndField(key, field, decodeErr)
+			} else if added {
+				h.opts.cacheLocally(key, field, t)
 			}
 		default:
 			if t == nil {
-				if opts.AddCacheMissErrors {
-					byKeysErr.AddErrorForKeyAndField(key, field, ErrCacheMiss)
+				if h.opts.AddCacheMissErrors {
+					h.byKeysErr.AddErrorForKeyAndField(key, field, ErrCacheMiss)
 				}
 			} else {
-				byKeysErr.AddErrorForKeyAndField(key, field, errors.Errorf("Non-handled type returned: %T", t))
+				h.byKeysErr.AddErrorForKeyAndField(key, field, errors.Errorf("Non-handled type returned: %T", t))
 			}
 		}
 	}
 }
 
-func handleStringStringMapCmd(opts Options, typedCmd *redis.StringStringMapCmd, container containers.Container, key string, byKeysErr *KeyErr) {
-	for field, val := range typedCmd.Val() {
-		decodeErr := decodeAndAddElementToContainer(opts, container, key, field, val)
+func (h *cmdsHandler) handleMGetCmd(typedCmd *redis.SliceCmd) {
+	keys := cmdKeys(typedCmd)
+	for keyIdx, val := range typedCmd.Val() {
+		key := keys[keyIdx]
+		switch t := val.(type) {
+		case string:
+			if added, decodeErr := h.decodeAndAdd(key, "", KeyField{Key: key}, t); decodeErr != nil {
+				h.byKeysErr.AddErrorForKey(key, decodeErr)
+			} else if added {
+				h.opts.cacheLocally(key, "", t)
+			}
+		case nil:
+			h.keysToRecheck = append(h.keysToRecheck, KeyField{Key: key})
+		default:
+			h.byKeysErr.AddErrorForKey(key, errors.Errorf("Non-handled type returned: %T", t))
+		}
+	}
+}
+
+func (h *cmdsHandler) handleStringCmd(typedCmd *redis.StringCmd, key string) {
+	added, decodeErr := h.decodeAndAdd(key, "", KeyField{Key: key}, typedCmd.Val())
+	if decodeErr != nil {
+		h.byKeysErr.AddErrorForKey(key, decodeErr)
+	} else if added {
+		h.opts.cacheLocally(key, "", typedCmd.Val())
+	}
+}
+
+func (h *cmdsHandler) handleStringStringMapCmd(typedCmd *redis.StringStringMapCmd, key string) {
+	fieldsToVals := h.withoutExpiredFields(typedCmd.Val())
+	for field, val := range fieldsToVals {
+		// the tombstone is outdated if some fields were set after it
+		if field == hashMapTombstoneField && len(fieldsToVals) > 1 {
+			continue
+		}
+		added, decodeErr := h.decodeAndAdd(key, field, KeyField{Key: key}, val)
 		if decodeErr != nil {
-			byKeysErr.AddErrorForKeyAndField(key, field, decodeErr)
+			h.byKeysErr.AddErrorForKeyAndField(key, field, decodeErr)
+		} else if added {
+			h.opts.cacheLocally(key, field, val)
 		}
 	}
 	// HGETALL doesn't return redis.Nil error for absent keys and returns just an empty list
-	if len(typedCmd.Val()) == 0 && opts.AddCacheMissErrors {
-		byKeysErr.AddErrorForKey(key, ErrCacheMiss)
+	if len(fieldsToVals) == 0 && h.opts.AddCacheMissErrors {
+		h.byKeysErr.AddErrorForKey(key, ErrCacheMiss)
 	}
 }
 
-func handleStringCmd(opts Options, typedCmd *redis.StringCmd, container containers.Container, key string, byKeysErr *KeyErr) {
-	decodeErr := decodeAndAddElementToContainer(opts, container, key, "", typedCmd.Val())
-	if decodeErr != nil {
-		byKeysErr.AddErrorForKey(key, decodeErr)
+// withoutExpiredFields filters out the hash map fields which TTL is emulated and has passed
+func (h *cmdsHandler) withoutExpiredFields(fieldsToVals map[string]string) map[string]string {
+	if !h.opts.HashFieldTTL {
+		return fieldsToVals
+	}
+	filtered := make(map[string]string, len(fieldsToVals))
+	for field, val := range fieldsToVals {
+		if !h.isExpiredField(val) {
+			filtered[field] = val
+		}
+	}
+	return filtered
+}
+
+// isExpiredField checks whether the hash map field TTL is emulated and has passed
+func (h *cmdsHandler) isExpiredField(val string) bool {
+	if !h.opts.HashFieldTTL {
+		return false
+	}
+	e, ok := h.opts.unwrap([]byte(val))
+	return ok && !e.tombstone && e.isExpired(h.now)
+}
+
+// addLocallyCached adds the keys found in the local cache into the container
+// and returns the keys which need to be read from Redis
+func (h *cmdsHandler) addLocallyCached(keys []KeyField) (missedKeys []KeyField) {
+	for _, kf := range keys {
+		key, field := kf.Key, kf.Field
+		val, ok := h.opts.LocalCache.Get(key, field)
+		// the expired hash map fields are dropped from the local cache and read from Redis
+		if ok && field != "" && h.isExpiredField(string(val)) {
+			h.opts.removeLocally(key, field)
+			ok = false
+		}
+		if ok {
+			// values picked for the early recomputation aren't added, but already marked as absent
+			if _, decodeErr := h.decodeAndAdd(key, field, kf, string(val)); decodeErr == nil {
+				continue
+			}
+		}
+		missedKeys = append(missedKeys, kf)
+	}
+	return missedKeys
+}
+
+// decodeAndAdd unmarshals the stored value and adds it into the container.
+// readKey is the key the value is read by, it's reloaded by the absent keys loader if the value needs to be refreshed.
+// The value isn't added if it's picked for the early recomputation: readKey is marked as a cache miss instead
+func (h *cmdsHandler) decodeAndAdd(key, field string, readKey KeyField, marshalledVal string) (added bool, err error) {
+	refreshKey := h.reader.errKey(readKey)
+	payload := []byte(marshalledVal)
+	var storedVersion uint32
+	if e, ok := h.opts.unwrap(payload); ok {
+		payload = e.payload
+		storedVersion = e.schemaVersion
+		if e.tombstone {
+			h.addTombstone(refreshKey, e)
+			return false, nil
+		}
+		// only the hash map fields with their own TTL might be read after expiration
+		if e.isExpired(h.now) {
+			if h.opts.AddCacheMissErrors {
+				h.byKeysErr.AddErrorForKey(refreshKey, ErrCacheMiss)
+			}
+			return false, nil
+		}
+		if h.shouldRecomputeEarly(e) {
+			h.byKeysErr.AddErrorForKey(refreshKey, ErrCacheMiss)
+			return false, nil
+		}
+		if e.isSoftExpired(h.now) {
+			h.keysToRefresh = append(h.keysToRefresh, readKey)
+		}
+	}
+	dstEl := h.container.DstEl()
+	if version, ok := h.opts.SchemaVersions.versionOf(dstEl); ok && version != storedVersion {
+		// the values stored by a newer release aren't replaced by the reloaded ones of the outdated version
+		if storedVersion > version {
+			return false, errors.Wrapf(ErrNewerSchemaVersion, "stored version %d is newer than %d", storedVersion, version)
+		}
+		return h.migrateAndAdd(key, field, refreshKey, storedVersion, payload, dstEl)
+	}
+	unmarshalErr := h.opts.Marshaller.Unmarshal(payload, dstEl)
+	if unmarshalErr != nil {
+		return false, unmarshalErr
+	}
+	addElementToContainer(h.opts, h.container, key, field, dstEl)
+	return true, nil
+}
+
+// migrateAndAdd adds the value stored with an outdated schema version if there is a migration for it,
+// otherwise refreshKey is marked as a cache miss to be reloaded.
+// The value is migrated into a new element, so dst isn't changed by a failed migration
+func (h *cmdsHandler) migrateAndAdd(key, field, refreshKey string, storedVersion uint32, payload []byte, dstEl interface{}) (added bool, err error) {
+	migration := h.opts.SchemaVersions.migration(dstEl, storedVersion)
+	if migration == nil {
+		if h.opts.AddCacheMissErrors {
+			h.byKeysErr.AddErrorForKey(refreshKey, ErrCacheMiss)
+		}
+		return false, nil
+	}
+	migrated := reflect.New(reflect.TypeOf(dstEl).Elem()).Interface()
+	if migrationErr := migration(storedVersion, payload, migrated); migrationErr != nil {
+		return false, migrationErr
+	}
+	addElementToContainer(h.opts, h.container, key, field, migrated)
+	return true, nil
+}
+
+// addTombstone reports the key known to be absent or just absent if the tombstone has expired
+func (h *cmdsHandler) addTombstone(key string, e envelope) {
+	if !h.opts.AddCacheMissErrors {
+		return
+	}
+	if e.isExpired(h.now) {
+		h.byKeysErr.AddErrorForKey(key, ErrCacheMiss)
+	} else {
+		h.byKeysErr.AddErrorForKey(key, ErrKnownAbsent)
+	}
+}
+
+// shouldRecomputeEarly checks whether the value should be reloaded before it expires.
+// It makes sense only if there is a loader to reload it with
+func (h *cmdsHandler) shouldRecomputeEarly(e envelope) bool {
+	if h.opts.EarlyRecomputeBeta <= 0 || !h.opts.hasAbsentKeysLoader() {
+		return false
 	}
+	// rand.Float64 returns values in [0, 1), but (0, 1] is needed for the logarithm
+	return e.shouldRecomputeEarly(h.now, h.opts.EarlyRecomputeBeta, 1-rand.Float64())
 }
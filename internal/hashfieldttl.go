@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const hashFieldTTLProbeTimeout = time.Second
+
+// hashFieldTTLSupport remembers whether the Redis server supports the native hash map fields expiration
+type hashFieldTTLSupport struct {
+	once   sync.Once
+	native bool
+}
+
+// nativeHashFieldTTL checks whether HPEXPIRE is supported by the server.
+// The server is probed once for all the caches sharing the options, a failed probe is remembered as well:
+// the expiration is emulated then
+func (opt Options) nativeHashFieldTTL(ctx context.Context) bool {
+	support := opt.hashFieldTTL
+	if support == nil {
+		return false
+	}
+	support.once.Do(func() {
+		probeCtx, cancel := context.WithTimeout(detachedContext{parent: ctx}, hashFieldTTLProbeTimeout)
+		defer cancel()
+		support.native, _ = detectNativeHashFieldTTL(probeCtx, opt.Redis)
+	})
+	return support.native
+}
+
+// detectNativeHashFieldTTL requests HPEXPIRE command info, it's empty for the unknown commands
+func detectNativeHashFieldTTL(ctx context.Context, rediser Rediser) (bool, error) {
+	pipeliner := rediser.Pipeline()
+	cmd := pipeliner.Do(ctx, "command", "info", "hpexpire")
+	if _, execErr := pipeliner.Exec(ctx); execErr != nil {
+		var redisErr redis.Error
+		// the servers without COMMAND INFO don't support HPEXPIRE either
+		if errors.As(execErr, &redisErr) {
+			return false, nil
+		}
+		return false, execErr
+	}
+	infos, ok := cmd.Val().([]interface{})
+	return ok && len(infos) == 1 && infos[0] != nil, nil
+}
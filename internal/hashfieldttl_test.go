@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	requireLib "github.com/stretchr/testify/require"
+
+	"github.com/vkuptcov/go-redis-cache/v8/marshallers"
+)
+
+// fieldTTLOptions creates the options with the hash map fields expiration mode set instead of probing the server
+func fieldTTLOptions(client *redis.Client, native bool) Options {
+	opts := InitOptions(Options{
+		Redis:        client,
+		Marshaller:   marshallers.NewMarshaller(&marshallers.JSONMarshaller{}),
+		HashFieldTTL: true,
+	})
+	opts.hashFieldTTL.once.Do(func() {
+		opts.hashFieldTTL.native = native
+	})
+	return opts
+}
+
+func setFieldsWithTTL(t *testing.T, opts Options, key string) {
+	t.Helper()
+	requireLib.NoError(t, SetMulti(
+		context.Background(),
+		opts,
+		&Item{Key: key, Field: "long", Value: "long-val", TTL: time.Hour},
+		&Item{Key: key, Field: "short", Value: "short-val", TTL: time.Second},
+	), "No error expected on setting")
+}
+
+func requireOnlyLongField(t *testing.T, opts Options, key string) {
+	t.Helper()
+	var dst map[string]map[string]string
+	requireLib.NoError(t, HGetAll(context.Background(), opts, &dst, []string{key}), "No error expected on getting all the fields")
+	requireLib.Equal(t, map[string]string{"long": "long-val"}, dst[key], "only the not expired field expected")
+}
+
+func TestHashFieldTTL_Emulated(t *testing.T) {
+	t.Parallel()
+	require := requireLib.New(t)
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	opts := fieldTTLOptions(client, false)
+	key := "emulated-field-ttl-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	defer client.Del(ctx, key)
+
+	setFieldsWithTTL(t, opts, key)
+	require.Greater(int64(client.TTL(ctx, key).Val()), int64(59*time.Minute), "the key must live as long as the longest field")
+
+	time.Sleep(time.Second)
+	require.EqualValues(2, client.HLen(ctx, key).Val(), "the expired field is kept in Redis")
+	requireOnlyLongField(t, opts, key)
+}
+
+func TestHashFieldTTL_Native(t *testing.T) {
+	t.Parallel()
+	require := requireLib.New(t)
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	if native, _ := detectNativeHashFieldTTL(ctx, client); !native {
+		t.Skip("HPEXPIRE isn't supported by the server")
+	}
+	opts := fieldTTLOptions(client, true)
+	key := "native-field-ttl-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	defer client.Del(ctx, key)
+
+	setFieldsWithTTL(t, opts, key)
+	require.Equal(time.Duration(-1), client.TTL(ctx, key).Val(), "the key TTL mustn't be set for the native fields expiration")
+	hpttl, hpttlErr := client.Do(ctx, "hpttl", key, "FIELDS", 2, "long", "short").Result()
+	require.NoError(hpttlErr, "No error expected on HPTTL")
+	fieldTTLs, ok := hpttl.([]interface{})
+	require.True(ok && len(fieldTTLs) == 2, "TTL of every field expected, %v given", hpttl)
+	longTTL, shortTTL := fieldTTLs[0].(int64), fieldTTLs[1].(int64)
+	require.Greater(longTTL, (59 * time.Minute).Milliseconds(), "unexpected TTL of the long field")
+	require.True(shortTTL > 0 && shortTTL <= time.Second.Milliseconds(), "unexpected TTL of the short field: %d", shortTTL)
+
+	time.Sleep(time.Second)
+	require.EqualValues(1, client.HLen(ctx, key).Val(), "the expired field must be removed by Redis")
+	requireOnlyLongField(t, opts, key)
+}
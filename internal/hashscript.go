@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	hashWriteIfNotExists = "nx"
+	hashWriteIfExists    = "xx"
+)
+
+// hash map TTL modes of hashWriteScript
+const (
+	// hashTTLReplace sets the key TTL
+	hashTTLReplace = ""
+	// hashTTLKeepLonger sets the key TTL unless the current one is longer
+	hashTTLKeepLonger = "longer"
+	// hashTTLField sets the field TTL via HPEXPIRE
+	hashTTLField = "field"
+	// hashTTLNewKey sets the TTL only for the keys created by the write
+	hashTTLNewKey = "new"
+)
+
+// hashWriteScript writes the hash map field along with its TTL.
+// ARGV are the field, the value, the condition (nx, xx or empty), the TTL in milliseconds
+// and the TTL mode (see hashTTLReplace, hashTTLKeepLonger, hashTTLField and hashTTLNewKey).
+// 1 is returned if the field is written, 0 if the condition isn't met
+var hashWriteScript = redis.NewScript(`
+local fieldExists = redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1
+if (ARGV[3] == "nx" and fieldExists) or (ARGV[3] == "xx" and not fieldExists) then
+	return 0
+end
+local keyExisted = redis.call("EXISTS", KEYS[1]) == 1
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+local ttl = tonumber(ARGV[4])
+if ttl > 0 then
+	if ARGV[5] == "field" then
+		redis.call("HPEXPIRE", KEYS[1], ttl, "FIELDS", 1, ARGV[1])
+		return 1
+	end
+	if ARGV[5] == "new" and keyExisted then
+		return 1
+	end
+	local currentTTL = redis.call("PTTL", KEYS[1])
+	if ARGV[5] ~= "longer" or not keyExisted or (currentTTL >= 0 and currentTTL < ttl) then
+		redis.call("PEXPIRE", KEYS[1], ttl)
+	end
+end
+return 1
+`)
+
+// writesHashAtomically checks whether the hash map item must be written via hashWriteScript
+func (opt Options) writesHashAtomically(item *Item) bool {
+	return item.Field != "" && (opt.AtomicHashWrites || opt.HashFieldTTL || item.IfExists || item.KeepLongerTTL || item.tombstone)
+}
+
+// hashTTLMode defines how the item TTL is applied to the hash map,
+// nativeFieldTTL is set if the server supports HPEXPIRE (see nativeHashFieldTTL)
+func (opt Options) hashTTLMode(item *Item, nativeFieldTTL bool) string {
+	switch {
+	case opt.HashFieldTTL && nativeFieldTTL:
+		return hashTTLField
+	// the field tombstones mustn't change the TTL of the existing fields, their expiration is checked on reading
+	case item.tombstone:
+		return hashTTLNewKey
+	// the expired fields are filtered out on reading, but the key must be kept for the longest living field
+	case opt.HashFieldTTL || item.KeepLongerTTL:
+		return hashTTLKeepLonger
+	default:
+		return hashTTLReplace
+	}
+}
+
+// writeHashItemAtomically adds the script call writing the item.
+// EVALSHA is used unless loadScript is set: EVAL loads the script then
+func writeHashItemAtomically(
+	ctx context.Context,
+	opts Options,
+	pipeliner redis.Pipeliner,
+	item *Item,
+	b []byte,
+	loadScript, nativeFieldTTL bool,
+) redis.Cmder {
+	condition := ""
+	switch {
+	case item.IfNotExists:
+		condition = hashWriteIfNotExists
+	case item.IfExists:
+		condition = hashWriteIfExists
+	}
+	keys := []string{item.Key}
+	ttl := opts.redisTTL(item.TTL)
+	args := []interface{}{item.Field, string(b), condition, ttl.Milliseconds(), opts.hashTTLMode(item, nativeFieldTTL)}
+	if loadScript {
+		return hashWriteScript.Eval(ctx, pipeliner, keys, args...)
+	}
+	return hashWriteScript.EvalSha(ctx, pipeliner, keys, args...)
+}
+
+// isNoScriptErr checks whether EVALSHA failed as the script isn't loaded into the Redis node
+func isNoScriptErr(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT ")
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+
+	"github.com/vkuptcov/go-redis-cache/v8/localcache"
+)
+
+const (
+	minResubscribeBackoff = 100 * time.Millisecond
+	maxResubscribeBackoff = 5 * time.Second
+)
+
+// invalidationMessage is published into the invalidation channel on Set/Delete calls
+type invalidationMessage struct {
+	// Keys are removed from the local caches together with all their hash map fields
+	Keys []string `json:"keys,omitempty"`
+
+	// Fields are hash map fields removed from the local caches without touching the rest of the key
+	Fields map[string][]string `json:"fields,omitempty"`
+}
+
+func (m *invalidationMessage) addKeyAndField(key, field string) {
+	if field == "" {
+		m.Keys = append(m.Keys, key)
+		return
+	}
+	if m.Fields == nil {
+		m.Fields = map[string][]string{}
+	}
+	m.Fields[key] = append(m.Fields[key], field)
+}
+
+func (m *invalidationMessage) isEmpty() bool {
+	return len(m.Keys) == 0 && len(m.Fields) == 0
+}
+
+func (m *invalidationMessage) apply(local *localcache.Cache) {
+	for _, k := range m.Keys {
+		local.DeleteKey(k)
+	}
+	for k, fields := range m.Fields {
+		for _, f := range fields {
+			local.Delete(k, f)
+		}
+	}
+}
+
+func publishInvalidation(ctx context.Context, opts Options, msg *invalidationMessage) error {
+	if opts.InvalidationChannel == "" || msg.isEmpty() {
+		return nil
+	}
+	publisher, ok := opts.Redis.(Publisher)
+	if !ok {
+		return ErrPublishNotSupported
+	}
+	payload, marshalErr := json.Marshal(msg)
+	if marshalErr != nil {
+		return errors.Wrap(marshalErr, "invalidation message marshalling failed")
+	}
+	return errors.Wrap(
+		publisher.Publish(ctx, opts.InvalidationChannel, payload).Err(),
+		"invalidation message publishing failed",
+	)
+}
+
+// Publisher is implemented by Redis clients supporting pub/sub.
+// Options.Redis must implement it if Options.InvalidationChannel is set
+type Publisher interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+}
+
+// Subscriber is implemented by Redis clients supporting pub/sub,
+// e.g. *redis.Client, *redis.ClusterClient and redis.UniversalClient
+type Subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// InvalidationSubscriber removes the keys published by other cache instances from the local cache.
+// As messages might be lost while the connection is broken,
+// the whole local cache is flushed every time the subscription is restored.
+type InvalidationSubscriber struct {
+	pubsub *redis.PubSub
+	local  *localcache.Cache
+	onErr  func(err error)
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	done      chan struct{}
+
+	subscribed bool
+}
+
+// SubscribeInvalidations starts listening for the invalidation messages published into the channel.
+// It stops on Close call or when ctx is done.
+// onErr is called for receive and decode errors and might be nil.
+func SubscribeInvalidations(ctx context.Context, client Subscriber, channel string, local *localcache.Cache, onErr func(err error)) *InvalidationSubscriber {
+	s := &InvalidationSubscriber{
+		pubsub: client.Subscribe(ctx, channel),
+		local:  local,
+		onErr:  onErr,
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run(ctx)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Close()
+		case <-s.closed:
+		}
+	}()
+	return s
+}
+
+// Close stops listening for the invalidation messages
+func (s *InvalidationSubscriber) Close() error {
+	var closeErr error
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		closeErr = s.pubsub.Close()
+	})
+	<-s.done
+	return closeErr
+}
+
+func (s *InvalidationSubscriber) run(ctx context.Context) {
+	defer close(s.done)
+	backoff := minResubscribeBackoff
+	for {
+		msg, receiveErr := s.pubsub.Receive(ctx)
+		if s.isClosed() {
+			return
+		}
+		if receiveErr != nil {
+			s.reportErr(errors.Wrap(receiveErr, "invalidation message receiving failed"))
+			// the next Receive call reconnects and resubscribes, so just slow down a bit here
+			select {
+			case <-s.closed:
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxResubscribeBackoff {
+				backoff = maxResubscribeBackoff
+			}
+			continue
+		}
+		backoff = minResubscribeBackoff
+		s.handle(msg)
+	}
+}
+
+func (s *InvalidationSubscriber) handle(msg interface{}) {
+	switch m := msg.(type) {
+	case *redis.Subscription:
+		if m.Kind != "subscribe" {
+			return
+		}
+		if s.subscribed {
+			s.local.Flush()
+		}
+		s.subscribed = true
+	case *redis.Message:
+		var im invalidationMessage
+		if unmarshalErr := json.Unmarshal([]byte(m.Payload), &im); unmarshalErr != nil {
+			s.reportErr(errors.Wrapf(unmarshalErr, "invalidation message %q decoding failed", m.Payload))
+			// it's unknown what should be removed, so remove everything
+			s.local.Flush()
+			return
+		}
+		im.apply(s.local)
+	}
+}
+
+func (s *InvalidationSubscriber) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *InvalidationSubscriber) reportErr(err error) {
+	if s.onErr != nil {
+		s.onErr(err)
+	}
+}
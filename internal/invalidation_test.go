@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	requireLib "github.com/stretchr/testify/require"
+
+	"github.com/vkuptcov/go-redis-cache/v8/localcache"
+)
+
+func TestInvalidationSubscriber_Handle(t *testing.T) {
+	require := requireLib.New(t)
+	local := localcache.New(localcache.Options{})
+	s := &InvalidationSubscriber{local: local}
+	fill := func() {
+		local.Set("k1", "", []byte("v"))
+		local.Set("k2", "", []byte("v"))
+		local.Set("h", "f1", []byte("v"))
+		local.Set("h", "f2", []byte("v"))
+	}
+	fill()
+
+	s.handle(&redis.Subscription{Kind: "subscribe", Channel: "ch", Count: 1})
+	require.Equal(4, local.Len(), "the first subscription mustn't flush the local cache")
+
+	s.handle(&redis.Message{Channel: "ch", Payload: `{"keys":["k1"],"fields":{"h":["f1"]}}`})
+	_, ok := local.Get("k1", "")
+	require.False(ok, "k1 must be removed")
+	_, ok = local.Get("h", "f1")
+	require.False(ok, "h/f1 must be removed")
+	_, ok = local.Get("h", "f2")
+	require.True(ok, "h/f2 must be kept")
+
+	s.handle(&redis.Subscription{Kind: "subscribe", Channel: "ch", Count: 1})
+	require.Zero(local.Len(), "resubscription must flush the local cache")
+
+	fill()
+	s.handle(&redis.Message{Channel: "ch", Payload: "broken message"})
+	require.Zero(local.Len(), "undecodable message must flush the local cache")
+}
@@ -21,11 +21,33 @@ type Item struct {
 	// Default TTL is taken from Options
 	TTL time.Duration
 
+	// SoftTTL is the time after which the value is considered stale:
+	// it's still returned from cache, but reloaded in the background via the absent keys loader.
+	// It should be less than TTL. Default soft TTL is taken from Options,
+	// see Options.DefaultSoftTTL for the options it requires
+	SoftTTL time.Duration
+
+	// ComputeDuration is how long it took to compute the value.
+	// It's used for the early recomputation (see Options.EarlyRecomputeBeta).
+	// The absent keys loader call duration is used for the loaded items without it
+	ComputeDuration time.Duration
+
 	// IfExists only sets the key if it already exist.
-	// Doesn't work if Field is set: Redis hash maps doesn't support it
+	// If Field is set, the field must exist: such items are written via a Lua script
 	IfExists bool
 
 	// IfNotExists only sets the key if it does not already exist.
 	// Only one of IfExists/IfNotExists can be setOne
 	IfNotExists bool
+
+	// KeepLongerTTL only extends the TTL of the hash map: the current TTL is kept if it's longer.
+	// Works only if Field is set, such items are written via a Lua script
+	KeepLongerTTL bool
+
+	// tombstone marks the keys the absent keys loader couldn't find, Value isn't stored for them
+	tombstone bool
+
+	// marshalled is the stored value of the loaded item,
+	// the callers sharing a coalesced load unmarshal it instead of sharing Value
+	marshalled []byte
 }
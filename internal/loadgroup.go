@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// loadGroup coalesces concurrent loads of the same absent keys set by the same loader,
+// so only one loader call is in flight for it and all the callers share its result.
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+type loadCall struct {
+	done  chan struct{}
+	ctx   *loadContext
+	items []*Item
+	err   error
+	// waiters is the number of the callers waiting for the load, it's guarded by loadGroup.mu
+	waiters int
+}
+
+func newLoadGroup() *loadGroup {
+	return &loadGroup{calls: map[string]*loadCall{}}
+}
+
+// do executes and returns the results of the given function,
+// making sure that only one execution is in-flight for the given scope and keys at a time.
+// If a duplicate comes in, the duplicate caller waits for the original
+// to complete and receives the same results.
+// The function runs on a context which isn't cancelled with the caller's one, so a cancelled caller doesn't fail the rest:
+// every caller stops waiting on its own ctx cancellation only.
+// The load is bounded by the latest deadline of the waiting callers and is cancelled once all of them have given up.
+// The shared value reports whether the results were received from another caller.
+func (g *loadGroup) do(ctx context.Context, scope string, keys []string, fn func(ctx context.Context) ([]*Item, error)) (items []*Item, shared bool, err error) {
+	groupKey := scope + "\x00" + loadGroupKey(keys)
+	g.mu.Lock()
+	c, shared := g.calls[groupKey]
+	// the load which deadline has passed isn't joined, a new one is started instead
+	if shared && c.ctx.Err() != nil {
+		shared = false
+	}
+	if !shared {
+		c = &loadCall{done: make(chan struct{}), ctx: newLoadContext(ctx)}
+		g.calls[groupKey] = c
+		go g.run(groupKey, c, fn)
+	}
+	c.waiters++
+	c.ctx.join(ctx)
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.items, shared, c.err
+	case <-ctx.Done():
+		g.leave(groupKey, c)
+		return nil, shared, ctx.Err()
+	}
+}
+
+// leave cancels the load if there are no callers waiting for it anymore,
+// the new callers start another load then
+func (g *loadGroup) leave(groupKey string, c *loadCall) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c.waiters--
+	if c.waiters > 0 {
+		return
+	}
+	if g.calls[groupKey] == c {
+		delete(g.calls, groupKey)
+	}
+	c.ctx.cancel(context.Canceled)
+}
+
+func (g *loadGroup) run(groupKey string, c *loadCall, fn func(ctx context.Context) ([]*Item, error)) {
+	defer func() {
+		// the loader runs in its own goroutine, so its panic is returned to the callers instead of crashing
+		if r := recover(); r != nil {
+			c.items, c.err = nil, errors.Errorf("absent keys loader panicked: %v", r)
+		}
+		g.mu.Lock()
+		if g.calls[groupKey] == c {
+			delete(g.calls, groupKey)
+		}
+		g.mu.Unlock()
+		c.ctx.cancel(context.Canceled)
+		close(c.done)
+	}()
+	c.items, c.err = fn(c.ctx)
+}
+
+// loadContext is the context a coalesced load runs on.
+// It keeps the values of the first caller, its deadline is the latest deadline of the callers joined the load,
+// and it has no deadline if some of them have none
+type loadContext struct {
+	parent context.Context
+
+	mu       sync.Mutex
+	deadline time.Time
+	// unbounded is set if some of the callers have no deadline
+	unbounded bool
+	timer     *time.Timer
+	done      chan struct{}
+	err       error
+}
+
+func newLoadContext(parent context.Context) *loadContext {
+	return &loadContext{parent: parent, done: make(chan struct{})}
+}
+
+// join extends the deadline of the load to the caller's one
+func (c *loadContext) join(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.unbounded {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		c.unbounded = true
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		return
+	}
+	if !deadline.After(c.deadline) {
+		return
+	}
+	c.deadline = deadline
+	if c.timer == nil {
+		c.timer = time.AfterFunc(time.Until(deadline), func() {
+			c.cancel(context.DeadlineExceeded)
+		})
+		return
+	}
+	c.timer.Reset(time.Until(deadline))
+}
+
+func (c *loadContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	// the timer might fire after the deadline is extended
+	if errors.Is(err, context.DeadlineExceeded) && (c.unbounded || time.Now().Before(c.deadline)) {
+		return
+	}
+	c.err = err
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	close(c.done)
+}
+
+func (c *loadContext) Deadline() (deadline time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.unbounded || c.deadline.IsZero() {
+		return time.Time{}, false
+	}
+	return c.deadline, true
+}
+
+func (c *loadContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *loadContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *loadContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func loadGroupKey(keys []string) string {
+	sorted := make([]string, len(keys))
+	copy(sorted, keys)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\x00")
+}
+
+var lastLoadScope uint64
+
+// WithNewLoadScope must be called every time the loader or the way the loaded items are transformed is changed,
+// so the loads of different loaders aren't coalesced
+func WithNewLoadScope(opt Options) Options {
+	opt.loadScope = strconv.FormatUint(atomic.AddUint64(&lastLoadScope, 1), 10)
+	return opt
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	requireLib "github.com/stretchr/testify/require"
+)
+
+func TestLoadContext_LatestDeadlineWins(t *testing.T) {
+	require := requireLib.New(t)
+	firstCtx, cancelFirst := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelFirst()
+	secondCtx, cancelSecond := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelSecond()
+
+	loadCtx := newLoadContext(firstCtx)
+	loadCtx.join(firstCtx)
+	loadCtx.join(secondCtx)
+	secondDeadline, _ := secondCtx.Deadline()
+	deadline, ok := loadCtx.Deadline()
+	require.True(ok, "the deadline expected")
+	require.Equal(secondDeadline, deadline, "the latest deadline expected")
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(loadCtx.Err(), "the load mustn't be cancelled till the latest deadline")
+	select {
+	case <-loadCtx.Done():
+	case <-time.After(time.Second):
+		require.Fail("the load must be cancelled after the latest deadline")
+	}
+	require.True(errors.Is(loadCtx.Err(), context.DeadlineExceeded), "deadline error expected, %+v given", loadCtx.Err())
+}
+
+func TestLoadContext_CallerWithoutDeadlineMakesLoadUnbounded(t *testing.T) {
+	require := requireLib.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	loadCtx := newLoadContext(ctx)
+	loadCtx.join(ctx)
+	loadCtx.join(context.Background())
+	_, ok := loadCtx.Deadline()
+	require.False(ok, "no deadline expected")
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(loadCtx.Err(), "the load without a deadline mustn't be cancelled")
+}
+
+func TestLoadGroup_LoadIsCancelledWhenAllCallersGaveUp(t *testing.T) {
+	require := requireLib.New(t)
+	g := newLoadGroup()
+	loadErr := make(chan error, 1)
+	started := make(chan struct{})
+	load := func(ctx context.Context) ([]*Item, error) {
+		close(started)
+		<-ctx.Done()
+		loadErr <- ctx.Err()
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	callerErr := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(ctx, "scope", []string{"key"}, load)
+		callerErr <- err
+	}()
+	<-started
+	cancel()
+	require.True(errors.Is(<-callerErr, context.Canceled), "the caller must stop waiting")
+	select {
+	case err := <-loadErr:
+		require.True(errors.Is(err, context.Canceled), "cancellation expected, %+v given", err)
+	case <-time.After(time.Second):
+		require.Fail("the load must be cancelled once all the callers gave up")
+	}
+}
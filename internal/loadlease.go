@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultLeaseKeyPrefix      = "\x00lease:"
+	defaultLeasePollInterval   = 10 * time.Millisecond
+	leaseReleaseTimeout        = time.Second
+	leaseTokenRandomBytesCount = 16
+)
+
+// LoadLeaseOptions configures distributed leases for absent keys loading.
+// If a lease for an absent key is held by another process, the key isn't loaded
+// but polled from cache until it appears there or WaitTimeout passes.
+// The key is loaded as usual if it's still absent after WaitTimeout.
+type LoadLeaseOptions struct {
+	// TTL is the lease lifetime. The leases are enabled if it is positive.
+	// A lease is released as soon as the loaded items are cached,
+	// so TTL only limits how long a crashed holder can block others.
+	TTL time.Duration
+
+	// WaitTimeout limits how long to wait for a key loaded by another lease holder.
+	// TTL is used by default
+	WaitTimeout time.Duration
+
+	// PollInterval is the interval to check whether the awaited keys are cached.
+	// 10ms by default
+	PollInterval time.Duration
+
+	// KeyPrefix is prepended to the absent keys to get the keys of their leases.
+	// The lease keys mustn't collide with the cached ones, the reserved "\x00lease:" prefix is used by default
+	KeyPrefix string
+}
+
+func (l LoadLeaseOptions) enabled() bool {
+	return l.TTL > 0
+}
+
+func (l LoadLeaseOptions) waitTimeout() time.Duration {
+	if l.WaitTimeout > 0 {
+		return l.WaitTimeout
+	}
+	return l.TTL
+}
+
+func (l LoadLeaseOptions) leaseKey(absentKey string) string {
+	if l.KeyPrefix != "" {
+		return l.KeyPrefix + absentKey
+	}
+	return defaultLeaseKeyPrefix + absentKey
+}
+
+func (l LoadLeaseOptions) pollInterval() time.Duration {
+	if l.PollInterval > 0 {
+		return l.PollInterval
+	}
+	return defaultLeasePollInterval
+}
+
+// releaseLeaseScript deletes the lease only if it's still held with the same token
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func addAbsentKeysUnderLease(ctx context.Context, opts Options, dst interface{}, reader keysReader, absentKeys []KeyField) error {
+	token, acquired, lost, leaseErr := acquireLoadLeases(ctx, opts, reader, absentKeys)
+	if leaseErr != nil {
+		return leaseErr
+	}
+	// the keys the loader failed for don't prevent waiting for the rest ones
+	var loaderKeysErr *KeyErr
+	if len(acquired) > 0 {
+		loadErr := loadAbsentKeysIntoContainer(ctx, opts, dst, reader, acquired)
+		releaseLoadLeases(ctx, opts, token, reader.errKeys(acquired))
+		if loadErr != nil && !errors.As(loadErr, &loaderKeysErr) {
+			return loadErr
+		}
+	}
+	if len(lost) > 0 {
+		stillAbsent, knownAbsentErr, waitErr := waitForAbsentKeys(ctx, opts, dst, reader, lost)
+		if waitErr != nil {
+			return waitErr
+		}
+		loaderKeysErr = mergeKeyErrs(loaderKeysErr, knownAbsentErr)
+		if len(stillAbsent) > 0 {
+			var stillAbsentKeysErr *KeyErr
+			loadErr := loadAbsentKeysIntoContainer(ctx, opts, dst, reader, stillAbsent)
+			if loadErr != nil && !errors.As(loadErr, &stillAbsentKeysErr) {
+				return loadErr
+			}
+			loaderKeysErr = mergeKeyErrs(loaderKeysErr, stillAbsentKeysErr)
+		}
+	}
+	if loaderKeysErr != nil {
+		return loaderKeysErr
+	}
+	return nil
+}
+
+// acquireLoadLeases tries to get a lease for every absent key.
+// The keys which leases couldn't be set because of Redis errors are treated as acquired
+// as it's better to load them once more than to wait for nothing.
+func acquireLoadLeases(ctx context.Context, opts Options, reader keysReader, absentKeys []KeyField) (token string, acquired, lost []KeyField, err error) {
+	tokenBytes := make([]byte, leaseTokenRandomBytesCount)
+	if _, err = rand.Read(tokenBytes); err != nil {
+		return "", nil, nil, errors.Wrap(err, "lease token generation failed")
+	}
+	token = hex.EncodeToString(tokenBytes)
+
+	pipeliner := opts.Redis.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(absentKeys))
+	for idx, kf := range absentKeys {
+		cmds[idx] = pipeliner.SetNX(ctx, opts.LoadLease.leaseKey(reader.errKey(kf)), token, opts.LoadLease.TTL)
+	}
+	// errors are checked for every command
+	_, _ = pipeliner.Exec(ctx)
+
+	for idx, cmd := range cmds {
+		if cmd.Err() != nil || cmd.Val() {
+			acquired = append(acquired, absentKeys[idx])
+		} else {
+			lost = append(lost, absentKeys[idx])
+		}
+	}
+	return token, acquired, lost, nil
+}
+
+// releaseLoadLeases removes the leases held by the token.
+// The leases are released even if ctx is cancelled, otherwise the others would wait for them till TTL passes.
+// Errors are ignored here: the leases expire anyway
+func releaseLoadLeases(ctx context.Context, opts Options, token string, keys []string) {
+	releaseCtx, cancel := context.WithTimeout(detachedContext{parent: ctx}, leaseReleaseTimeout)
+	defer cancel()
+	pipeliner := opts.Redis.Pipeline()
+	for _, k := range keys {
+		releaseLeaseScript.Eval(releaseCtx, pipeliner, []string{opts.LoadLease.leaseKey(k)}, token)
+	}
+	_, _ = pipeliner.Exec(releaseCtx)
+}
+
+// waitForAbsentKeys polls cache for the keys loaded by other lease holders
+// and returns the keys which are still absent after the wait timeout.
+// The keys the lease holders found absent are reported via KeyErr the same way as they're read from cache
+func waitForAbsentKeys(ctx context.Context, opts Options, dst interface{}, reader keysReader, keys []KeyField) (stillAbsent []KeyField, knownAbsentErr *KeyErr, err error) {
+	timeout := time.NewTimer(opts.LoadLease.waitTimeout())
+	defer timeout.Stop()
+	ticker := time.NewTicker(opts.LoadLease.pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-timeout.C:
+			return keys, knownAbsentErr, nil
+		case <-ticker.C:
+		}
+		pollErr := execAndAddIntoContainer(ctx, opts, dst, keys, reader)
+		if pollErr == nil {
+			return nil, knownAbsentErr, nil
+		}
+		var byKeyErr *KeyErr
+		if !errors.As(pollErr, &byKeyErr) || byKeyErr.HasNonCacheMissErrs() {
+			return nil, nil, pollErr
+		}
+		// the keys the lease holders found absent aren't waited for
+		knownAbsentErr = mergeKeyErrs(knownAbsentErr, byKeyErr.withoutCacheMisses())
+		keys = reader.keysWithErr(keys, byKeyErr, ErrCacheMiss)
+		if len(keys) == 0 {
+			return nil, knownAbsentErr, nil
+		}
+	}
+}
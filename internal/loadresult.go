@@ -0,0 +1,41 @@
+package internal
+
+// LoadResult might be returned from the absent keys loaders which fail only for some of the keys.
+// The loaded data is cached and added into the destination as usual,
+// the errors are returned within KeyErr.
+type LoadResult struct {
+	// Data is the loaded data in any format supported for the loaders' results
+	Data interface{}
+
+	// KeysToErrs are the errors for the absent keys which couldn't be loaded
+	KeysToErrs map[string]error
+}
+
+// splitLoadResult extracts the loaded data and the per key errors from the loader's result
+func splitLoadResult(data interface{}) (loaded interface{}, keysErr *KeyErr) {
+	var res LoadResult
+	switch typed := data.(type) {
+	case LoadResult:
+		res = typed
+	case *LoadResult:
+		if typed == nil {
+			return nil, nil
+		}
+		res = *typed
+	default:
+		return data, nil
+	}
+	if len(res.KeysToErrs) == 0 {
+		return res.Data, nil
+	}
+	keysErr = &KeyErr{KeysToErrs: make(map[string]error, len(res.KeysToErrs))}
+	for k, err := range res.KeysToErrs {
+		if err != nil {
+			keysErr.AddErrorForKey(k, err)
+		}
+	}
+	if len(keysErr.KeysToErrs) == 0 {
+		return res.Data, nil
+	}
+	return res.Data, keysErr
+}
@@ -0,0 +1,19 @@
+package internal
+
+func (opt Options) cacheLocally(key, field, marshalledVal string) {
+	if opt.LocalCache != nil {
+		opt.LocalCache.Set(key, field, []byte(marshalledVal))
+	}
+}
+
+func (opt Options) removeLocally(key, field string) {
+	if opt.LocalCache != nil {
+		opt.LocalCache.Delete(key, field)
+	}
+}
+
+func (opt Options) removeKeyLocally(key string) {
+	if opt.LocalCache != nil {
+		opt.LocalCache.DeleteKey(key)
+	}
+}
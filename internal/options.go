@@ -1,8 +1,10 @@
 package internal
 
 import (
+	"context"
 	"time"
 
+	"github.com/vkuptcov/go-redis-cache/v8/localcache"
 	"github.com/vkuptcov/go-redis-cache/v8/marshallers"
 )
 
@@ -17,6 +19,10 @@ type Options struct {
 
 	AbsentKeysLoader func(absentKeys ...string) (interface{}, error)
 
+	// ContextAbsentKeysLoader is the same as AbsentKeysLoader, but it receives the context
+	// passed to the *Get methods. It takes precedence over AbsentKeysLoader if both are set.
+	ContextAbsentKeysLoader func(ctx context.Context, absentKeys ...string) (interface{}, error)
+
 	CacheKeyExtractor func(it interface{}) (key, field string)
 
 	TransformCacheKeyForDestination func(key, field string, val interface{}) (newKey, newField string, skip bool)
@@ -24,6 +30,199 @@ type Options struct {
 	AddCacheMissErrors bool
 
 	DisableCacheMissErrorsForSingleElementDst bool
+
+	// CoalesceLoads makes concurrent *Get calls missing the same set of keys
+	// share a single absent keys loader call.
+	// Only the first caller runs the loader and stores the loaded items,
+	// other callers wait for it and receive the loaded items into their own destinations:
+	// the stored values are unmarshalled for them, so the loaded objects aren't shared between the callers.
+	// The loads are coalesced only for the calls using the same loader: the calls of the same cache
+	// or of the caches derived from it without changing the loader or the CacheKeyExtractor.
+	// The *OrLoad calls are coalesced only for the loaders with the same Loader.Name.
+	// The loader runs on a context with the latest deadline of the waiting callers,
+	// it's cancelled once all of them have given up rather than on the cancellation of a single caller.
+	CoalesceLoads bool
+
+	// LoadLease makes processes sharing the same Redis coordinate loading of absent keys:
+	// only a lease holder loads a key, others wait for it to appear in cache.
+	// It's disabled by default.
+	LoadLease LoadLeaseOptions
+
+	// LocalCache is an optional in-process tier which is checked before Redis.
+	// It's populated with the values read from Redis and loaded by the absent keys loader,
+	// and invalidated on Set/Delete calls of the caches sharing it.
+	// HGetAll always reads from Redis as the local cache can't tell whether it has all the hash map fields.
+	LocalCache *localcache.Cache
+
+	// InvalidationChannel is a Redis pub/sub channel the keys changed by Set/Delete calls are published into.
+	// The local caches of other instances are kept in sync via InvalidationSubscriber listening to it.
+	// Nothing is published if it's empty
+	InvalidationChannel string
+
+	// DefaultSoftTTL is the soft TTL for the items without Item.SoftTTL.
+	// The values are stored without soft TTL if it isn't positive.
+	// The stale values are refreshed only if an absent keys loader is set.
+	// Item.SoftTTL is ignored unless the values are stored with metadata: DefaultSoftTTL, EarlyRecomputeBeta,
+	// NegativeTTL, HashFieldTTL or SchemaVersions enable it, and only such caches read the metadata back,
+	// so the caches sharing the same keys should be configured alike
+	DefaultSoftTTL time.Duration
+
+	// BackgroundRefreshWorkers limits the number of concurrent refreshes of stale values.
+	// 4 by default
+	BackgroundRefreshWorkers int
+
+	// BackgroundRefreshQueueSize limits the number of refreshes waiting for a worker.
+	// If the queue is full, the refresh is dropped and ErrRefreshQueueFull is reported.
+	// 100 by default
+	BackgroundRefreshQueueSize int
+
+	// OnBackgroundRefreshError is called for failed refreshes of stale values
+	OnBackgroundRefreshError func(keys []string, err error)
+
+	// EarlyRecomputeBeta enables the probabilistic early expiration (XFetch) if it's positive.
+	// On every read a value is treated as absent and reloaded via the absent keys loader
+	// with the probability growing as its expiration approaches.
+	// The more time it took to compute the value (see Item.ComputeDuration), the earlier it's recomputed.
+	// Values greater than 1 favour earlier recomputation, values less than 1 favour later one.
+	// 1 is a reasonable default.
+	EarlyRecomputeBeta float64
+
+	// NegativeTTL enables the negative caching if it's positive.
+	// The keys requested from the absent keys loader but not returned by it are stored as tombstones
+	// for NegativeTTL and reported as ErrKnownAbsent instead of being loaded again.
+	// The hash map fields tombstones don't change the TTL of the hash map.
+	// The hash maps read entirely are marked absent with the reserved "\x00tombstone" field
+	NegativeTTL time.Duration
+
+	// GroupPipelinesBySlot splits the pipelines by the Redis Cluster hash slots of the keys
+	// and executes them concurrently, so a failed node fails only the commands for its keys.
+	// It's enabled automatically for *redis.ClusterClient where the keys are grouped by master nodes.
+	GroupPipelinesBySlot bool
+
+	// PipelineConcurrency limits the number of the pipelines executed concurrently.
+	// 8 by default
+	PipelineConcurrency int
+
+	// MaxPipelineCommands splits the large batches into several pipelines, there is no limit by default.
+	// The multi-key commands (MGET, HMGET and HSET of HSetKV) are counted by their keys or fields.
+	// The hash map items written without a Lua script take two commands: HSET and EXPIRE.
+	// The chunks of the same pipeline are executed one by one, so the writes of the same keys aren't reordered,
+	// only the pipelines of different Redis Cluster nodes or slots are executed concurrently.
+	MaxPipelineCommands int
+
+	// ScanBatchSize is the COUNT hint of SCAN and the max number of keys deleted at once by the pattern deletion.
+	// 1000 by default
+	ScanBatchSize int
+
+	// AtomicHashWrites writes every hash map field along with the key TTL in a single Lua script call,
+	// so a hash map isn't left without TTL if a connection breaks in between.
+	// The items with IfExists or KeepLongerTTL are always written this way.
+	AtomicHashWrites bool
+
+	// HashFieldTTL applies Item.TTL of the hash map items to their fields instead of the whole key.
+	// The native fields expiration (HPEXPIRE) is used if the Redis server supports it, the server is probed on the first write.
+	// Otherwise the expiration time is stored along with the value: the expired fields are filtered out on reading
+	// and the key TTL is extended only to keep the longest living field.
+	// The hash map items are written via a Lua script then, see AtomicHashWrites.
+	HashFieldTTL bool
+
+	// SchemaVersions enables versioning of the cached values of the registered types.
+	// The version is stored along with the value and checked on reading:
+	// the values with an older version are migrated or treated as absent and reloaded by the absent keys loader,
+	// the values with a newer version fail with ErrNewerSchemaVersion and aren't overwritten
+	SchemaVersions *SchemaVersions
+
+	loadGroup *loadGroup
+	// loadScope identifies the loader, the loads are coalesced only within the same non-empty scope
+	loadScope    string
+	refresher    *backgroundRefresher
+	hashFieldTTL *hashFieldTTLSupport
+}
+
+// InitOptions creates the state shared between all the caches derived from the same options
+func InitOptions(opt Options) Options {
+	if opt.CoalesceLoads {
+		opt.loadGroup = newLoadGroup()
+		opt = WithNewLoadScope(opt)
+	}
+	if opt.HashFieldTTL {
+		opt.hashFieldTTL = &hashFieldTTLSupport{}
+	}
+	opt.refresher = newBackgroundRefresher(opt)
+	return opt
+}
+
+// CloseOptions stops the background workers created by InitOptions
+func CloseOptions(opt Options) {
+	if opt.refresher != nil {
+		opt.refresher.close()
+	}
+}
+
+// usesEnvelopes checks whether the values might be stored with metadata, see envelope.
+// The stored values are unwrapped only then, so the raw values which start with the envelope magic
+// are read as is by the caches without the options below
+func (opt Options) usesEnvelopes() bool {
+	return opt.DefaultSoftTTL > 0 ||
+		opt.EarlyRecomputeBeta > 0 ||
+		opt.NegativeTTL > 0 ||
+		opt.HashFieldTTL ||
+		opt.SchemaVersions != nil
+}
+
+// unwrap extracts the envelope from the stored value if the envelopes are used.
+// ok is false if the value isn't enveloped
+func (opt Options) unwrap(data []byte) (e envelope, ok bool) {
+	if !opt.usesEnvelopes() {
+		return envelope{}, false
+	}
+	return unmarshalEnvelope(data)
+}
+
+// softTTL is applied only if the envelopes are used, otherwise the soft expiry wouldn't be read
+func (opt Options) softTTL(itemSoftTTL time.Duration) time.Duration {
+	if !opt.usesEnvelopes() {
+		return 0
+	}
+	if itemSoftTTL > 0 {
+		return itemSoftTTL
+	}
+	return opt.DefaultSoftTTL
+}
+
+// marshal marshals the item value and wraps it into an envelope if it's needed
+func (opt Options) marshal(item *Item) ([]byte, error) {
+	if item.tombstone {
+		return envelope{
+			expiresAt: time.Now().Add(opt.NegativeTTL),
+			tombstone: true,
+		}.marshal(), nil
+	}
+	b, marshalErr := opt.Marshaller.Marshal(item.Value)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	now := time.Now()
+	var e envelope
+	if softTTL := opt.softTTL(item.SoftTTL); softTTL > 0 {
+		e.softExpiresAt = now.Add(softTTL)
+	}
+	// the expiration time is needed for the early recomputation and to filter out the expired hash map fields
+	hasFieldTTL := opt.HashFieldTTL && item.Field != ""
+	if ttl := opt.redisTTL(item.TTL); (opt.EarlyRecomputeBeta > 0 || hasFieldTTL) && ttl > 0 {
+		e.expiresAt = now.Add(ttl)
+		e.computeDuration = item.ComputeDuration
+	}
+	if version, ok := opt.SchemaVersions.versionOf(item.Value); ok {
+		e.schemaVersion = version
+	}
+	// the raw values looking like envelopes are enveloped to be read unambiguously
+	hasMetadata := !e.softExpiresAt.IsZero() || !e.expiresAt.IsZero() || e.schemaVersion > 0
+	if !hasMetadata && !(opt.usesEnvelopes() && hasEnvelopeMagic(b)) {
+		return b, nil
+	}
+	e.payload = b
+	return e.marshal(), nil
 }
 
 func (opt Options) redisTTL(itemTTL time.Duration) time.Duration {
@@ -35,3 +234,43 @@ func (opt Options) redisTTL(itemTTL time.Duration) time.Duration {
 	}
 	return itemTTL
 }
+
+// Loader loads the keys absent in cache for a single call
+type Loader struct {
+	// Load returns the loaded items in the same formats as Options.ContextAbsentKeysLoader does
+	Load func(ctx context.Context, absentKeys ...string) (interface{}, error)
+
+	// CacheKeyExtractor transforms the loaded items into keys and fields.
+	// Options.CacheKeyExtractor is used if it's not set
+	CacheKeyExtractor func(it interface{}) (key, field string)
+
+	// Name identifies the loader for Options.CoalesceLoads: concurrent calls missing the same keys
+	// share the load only if their loaders have the same non-empty Name,
+	// so such loaders must be interchangeable. The loads aren't coalesced if it's empty
+	Name string
+}
+
+// OptionsWithLoader returns the options using the loader instead of the configured ones
+func OptionsWithLoader(opt Options, loader Loader) Options {
+	opt.ContextAbsentKeysLoader = loader.Load
+	opt.AbsentKeysLoader = nil
+	if loader.CacheKeyExtractor != nil {
+		opt.CacheKeyExtractor = loader.CacheKeyExtractor
+	}
+	opt.loadScope = ""
+	if loader.Name != "" {
+		opt.loadScope = "loader:" + loader.Name
+	}
+	return opt
+}
+
+func (opt Options) hasAbsentKeysLoader() bool {
+	return opt.ContextAbsentKeysLoader != nil || opt.AbsentKeysLoader != nil
+}
+
+func (opt Options) loadAbsentKeys(ctx context.Context, absentKeys []string) (interface{}, error) {
+	if opt.ContextAbsentKeysLoader != nil {
+		return opt.ContextAbsentKeysLoader(ctx, absentKeys...)
+	}
+	return opt.AbsentKeysLoader(absentKeys...)
+}
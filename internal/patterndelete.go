@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const defaultScanBatchSize = 1000
+
+// DeleteByPatternStats describes the progress of the deletion by a pattern
+type DeleteByPatternStats struct {
+	// Scanned is the number of the keys found by the pattern
+	Scanned int64
+	// Deleted is the number of the keys actually deleted,
+	// it might be less than Scanned if some keys were expired or deleted in between
+	Deleted int64
+}
+
+func (opt Options) scanBatchSize() int {
+	if opt.ScanBatchSize > 0 {
+		return opt.ScanBatchSize
+	}
+	return defaultScanBatchSize
+}
+
+// DeleteByPattern iterates over the keys matching the pattern via SCAN and deletes them via UNLINK in batches.
+// Every master node is scanned for *redis.ClusterClient.
+// onProgress might be nil, otherwise it's called with the total stats after every deleted batch.
+// The stats are returned even if the deletion is interrupted by an error or by ctx cancellation
+func DeleteByPattern(ctx context.Context, opts Options, pattern string, onProgress func(stats DeleteByPatternStats)) (DeleteByPatternStats, error) {
+	d := &patternDeletion{opts: opts, pattern: pattern, onProgress: onProgress}
+	var deleteErr error
+	if clusterClient, ok := opts.Redis.(*redis.ClusterClient); ok {
+		deleteErr = clusterClient.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return d.deleteFromNode(ctx, node)
+		})
+	} else {
+		deleteErr = d.deleteFromNode(ctx, opts.Redis)
+	}
+	return d.totalStats(), deleteErr
+}
+
+// patternDeletion collects the stats of the nodes scanned concurrently
+type patternDeletion struct {
+	opts       Options
+	pattern    string
+	onProgress func(stats DeleteByPatternStats)
+
+	mu    sync.Mutex
+	stats DeleteByPatternStats
+}
+
+// deleteFromNode scans the node until the cursor returns to zero.
+// Rediser doesn't expose SCAN, so the commands are sent via single command pipelines
+func (d *patternDeletion) deleteFromNode(ctx context.Context, node Rediser) error {
+	var cursor uint64
+	for {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		pipeliner := node.Pipeline()
+		scanCmd := pipeliner.Scan(ctx, cursor, d.pattern, int64(d.opts.scanBatchSize()))
+		if _, scanErr := pipeliner.Exec(ctx); scanErr != nil {
+			return scanErr
+		}
+		keys, nextCursor := scanCmd.Val()
+		for _, chunk := range chunkForPipelines([][]string{keys}, d.opts.scanBatchSize(), nil) {
+			if len(chunk) == 0 {
+				continue
+			}
+			if unlinkErr := d.unlink(ctx, node, chunk); unlinkErr != nil {
+				return unlinkErr
+			}
+		}
+		if nextCursor == 0 {
+			return nil
+		}
+		cursor = nextCursor
+	}
+}
+
+// unlink deletes the keys found on the node.
+// The keys are split by slots for the clustered Redis, as multi-key commands can't touch different slots there,
+// and are unlinked one by one for the clients which might route them to different shards
+func (d *patternDeletion) unlink(ctx context.Context, node Rediser, keys []string) error {
+	groupKey, ok := d.opts.multiKeyGroupKeyFn()
+	if !ok {
+		groupKey = sameKey
+	}
+	pipeliner := node.Pipeline()
+	groups := groupForPipelines(groupKey, keys, sameKey)
+	cmds := make([]*redis.IntCmd, 0, len(groups))
+	for _, group := range groups {
+		cmds = append(cmds, pipeliner.Unlink(ctx, group...))
+	}
+	_, unlinkErr := pipeliner.Exec(ctx)
+	var deleted int64
+	for _, cmd := range cmds {
+		deleted += cmd.Val()
+	}
+
+	var msg invalidationMessage
+	for _, k := range keys {
+		d.opts.removeKeyLocally(k)
+		msg.addKeyAndField(k, "")
+	}
+	publishErr := publishInvalidation(ctx, d.opts, &msg)
+	d.addProgress(int64(len(keys)), deleted)
+	if unlinkErr != nil {
+		return unlinkErr
+	}
+	return publishErr
+}
+
+func (d *patternDeletion) addProgress(scanned, deleted int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stats.Scanned += scanned
+	d.stats.Deleted += deleted
+	if d.onProgress != nil {
+		d.onProgress(d.stats)
+	}
+}
+
+func (d *patternDeletion) totalStats() DeleteByPatternStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats
+}
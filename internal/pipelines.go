@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+const defaultPipelineConcurrency = 8
+
+// pipelineGroupKeyFn returns the function grouping the Redis keys into pipelines.
+// It's nil if all the keys go into the same pipeline.
+// Redis Cluster keys are grouped by the master nodes serving their slots,
+// the error is returned for the keys which nodes are unknown.
+func (opt Options) pipelineGroupKeyFn(ctx context.Context) func(key string) (string, error) {
+	if clusterClient, ok := opt.Redis.(*redis.ClusterClient); ok {
+		return func(key string) (string, error) {
+			node, nodeErr := clusterClient.MasterForKey(ctx, key)
+			if nodeErr != nil {
+				return "", errors.Wrap(nodeErr, "cluster node lookup failed")
+			}
+			return node.Options().Addr, nil
+		}
+	}
+	if opt.GroupPipelinesBySlot {
+		return func(key string) (string, error) {
+			return bySlot(key), nil
+		}
+	}
+	return nil
+}
+
+func bySlot(key string) string {
+	return strconv.Itoa(cachekeys.HashSlot(key))
+}
+
+// isClustered checks whether the keys might be served by different Redis Cluster nodes
+func (opt Options) isClustered() bool {
+	if opt.GroupPipelinesBySlot {
+		return true
+	}
+	_, ok := opt.Redis.(*redis.ClusterClient)
+	return ok
+}
+
+// multiKeyGroupKeyFn returns the function grouping the keys of the multi-key commands (MGET, UNLINK).
+// All the keys go into the same command for a standalone *redis.Client and are grouped by slots for clusters.
+// ok is false for the rest clients (e.g. *redis.Ring or custom Redisers), as they might serve the keys
+// of a single command from different shards, so the keys have to be sent one by one
+func (opt Options) multiKeyGroupKeyFn() (groupKey func(key string) string, ok bool) {
+	if opt.isClustered() {
+		return bySlot, true
+	}
+	_, isStandalone := opt.Redis.(*redis.Client)
+	return nil, isStandalone
+}
+
+func (opt Options) pipelineConcurrency() int {
+	if opt.PipelineConcurrency > 0 {
+		return opt.PipelineConcurrency
+	}
+	return defaultPipelineConcurrency
+}
+
+// execPipelined runs the commands added by fill for the elements in pipelines, fill returns the added commands.
+// The elements are grouped by their Redis keys (see pipelineGroupKeyFn),
+// the groups are executed concurrently and are split into chunks of up to Options.MaxPipelineCommands commands.
+// The chunks of a group are executed sequentially in the order of the elements.
+// The errors are set for every command, so the returned commands are the only result.
+// The commands of the elements which pipelines can't be found aren't sent, the lookup error is set for them.
+func execPipelined[T any](
+	ctx context.Context,
+	opts Options,
+	elems []T,
+	redisKey func(el T) string,
+	fill func(pipeliner redis.Pipeliner, elems []T) []redis.Cmder,
+) []redis.Cmder {
+	return execWeightedPipelined(ctx, opts, elems, redisKey, nil, fill)
+}
+
+// execWeightedPipelined is the same as execPipelined for the elements taking several commands:
+// cmdsCount returns how many commands fill adds for the element.
+// Every element takes a single command if cmdsCount is nil.
+func execWeightedPipelined[T any](
+	ctx context.Context,
+	opts Options,
+	elems []T,
+	redisKey func(el T) string,
+	cmdsCount func(el T) int,
+	fill func(pipeliner redis.Pipeliner, elems []T) []redis.Cmder,
+) []redis.Cmder {
+	groups, failed := lookupPipelines(opts.pipelineGroupKeyFn(ctx), elems, redisKey)
+	groupsCmds := make([][]redis.Cmder, len(groups))
+	execGroup := func(idx int) {
+		// the chunks of a group are executed one by one, so the commands for the same keys aren't reordered
+		for _, chunk := range chunkForPipelines([][]T{groups[idx]}, opts.MaxPipelineCommands, cmdsCount) {
+			pipeliner := opts.Redis.Pipeline()
+			fill(pipeliner, chunk)
+			// the errors are checked for every command
+			cmds, _ := pipeliner.Exec(ctx)
+			groupsCmds[idx] = append(groupsCmds[idx], cmds...)
+		}
+	}
+	if len(groups) == 1 && len(failed) == 0 {
+		execGroup(0)
+		return groupsCmds[0]
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, opts.pipelineConcurrency())
+	for idx := range groups {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			execGroup(idx)
+		}(idx)
+	}
+	wg.Wait()
+
+	totalCmds := 0
+	for _, cmds := range groupsCmds {
+		totalCmds += len(cmds)
+	}
+	allCmds := make([]redis.Cmder, 0, totalCmds)
+	for _, cmds := range groupsCmds {
+		allCmds = append(allCmds, cmds...)
+	}
+	for _, f := range failed {
+		allCmds = append(allCmds, failedCmds(opts, f, fill)...)
+	}
+	return allCmds
+}
+
+// failedElem is the element which pipeline can't be found
+type failedElem[T any] struct {
+	el  T
+	err error
+}
+
+// failedCmds returns the commands of the failed element with its error set without sending them
+func failedCmds[T any](
+	opts Options,
+	f failedElem[T],
+	fill func(pipeliner redis.Pipeliner, elems []T) []redis.Cmder,
+) []redis.Cmder {
+	pipeliner := opts.Redis.Pipeline()
+	cmds := fill(pipeliner, []T{f.el})
+	_ = pipeliner.Discard()
+	for _, cmd := range cmds {
+		cmd.SetErr(f.err)
+	}
+	return cmds
+}
+
+// groupForPipelines splits the elements into groups keeping the order of their first appearance
+func groupForPipelines[T any](groupKey func(key string) string, elems []T, redisKey func(el T) string) [][]T {
+	if groupKey == nil {
+		return [][]T{elems}
+	}
+	groups, _ := lookupPipelines(func(key string) (string, error) {
+		return groupKey(key), nil
+	}, elems, redisKey)
+	return groups
+}
+
+// lookupPipelines is the same as groupForPipelines for the group keys which lookup might fail,
+// the elements which group keys can't be found are returned as failed
+func lookupPipelines[T any](
+	groupKey func(key string) (string, error),
+	elems []T,
+	redisKey func(el T) string,
+) (groups [][]T, failed []failedElem[T]) {
+	if groupKey == nil {
+		return [][]T{elems}, nil
+	}
+	groupIdx := map[string]int{}
+	for _, el := range elems {
+		gk, gkErr := groupKey(redisKey(el))
+		if gkErr != nil {
+			failed = append(failed, failedElem[T]{el: el, err: gkErr})
+			continue
+		}
+		idx, ok := groupIdx[gk]
+		if !ok {
+			idx = len(groups)
+			groupIdx[gk] = idx
+			groups = append(groups, nil)
+		}
+		groups[idx] = append(groups[idx], el)
+	}
+	return groups, failed
+}
+
+// chunkForPipelines splits the groups taking more than maxCmds commands, maxCmds <= 0 means no limit.
+// cmdsCount returns the number of commands of the element, every element takes a single command if it's nil.
+// An element taking more than maxCmds commands gets a chunk of its own
+func chunkForPipelines[T any](groups [][]T, maxCmds int, cmdsCount func(el T) int) [][]T {
+	if maxCmds <= 0 {
+		return groups
+	}
+	chunks := make([][]T, 0, len(groups))
+	for _, group := range groups {
+		start, cmds := 0, 0
+		for idx, el := range group {
+			elCmds := 1
+			if cmdsCount != nil {
+				elCmds = cmdsCount(el)
+			}
+			if cmds+elCmds > maxCmds && idx > start {
+				chunks = append(chunks, group[start:idx:idx])
+				start, cmds = idx, 0
+			}
+			cmds += elCmds
+		}
+		chunks = append(chunks, group[start:])
+	}
+	return chunks
+}
+
+// cmdsKeyErr maps the failed commands to their keys, HDEL is mapped to the keys with fields.
+// redis.Nil isn't treated as an error. Nil is returned if all the commands succeeded.
+func cmdsKeyErr(cmds []redis.Cmder) *KeyErr {
+	var keyErr *KeyErr
+	for _, cmd := range cmds {
+		err := cmd.Err()
+		if err == nil || err == redis.Nil {
+			continue
+		}
+		if keyErr == nil {
+			keyErr = &KeyErr{KeysToErrs: map[string]error{}}
+		}
+		if cmd.Name() != "hdel" {
+			keyErr.AddErrorForKey(cmdKey(cmd), err)
+			continue
+		}
+		for _, arg := range cmd.Args()[2:] {
+			field, _ := arg.(string)
+			keyErr.AddErrorForKeyAndField(cmdKey(cmd), field, err)
+		}
+	}
+	return keyErr
+}
+
+func cmdKey(cmd redis.Cmder) string {
+	key, _ := cmd.Args()[1].(string)
+	return key
+}
+
+// cmdKeys returns all the keys of the multi-key commands or the only key of the rest ones
+func cmdKeys(cmd redis.Cmder) []string {
+	if cmd.Name() != "mget" {
+		return []string{cmdKey(cmd)}
+	}
+	keys := make([]string, 0, len(cmd.Args())-1)
+	for _, arg := range cmd.Args()[1:] {
+		key, _ := arg.(string)
+		keys = append(keys, key)
+	}
+	return keys
+}
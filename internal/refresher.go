@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBackgroundRefreshWorkers   = 4
+	defaultBackgroundRefreshQueueSize = 100
+)
+
+// backgroundRefresher reloads stale keys via a bounded pool of workers.
+// The workers are started on the first refresh.
+type backgroundRefresher struct {
+	workers int
+	tasks   chan refreshTask
+	onErr   func(keys []string, err error)
+
+	startOnce sync.Once
+	wg        sync.WaitGroup
+
+	mu       sync.Mutex
+	closed   bool
+	inFlight map[string]struct{}
+}
+
+type refreshTask struct {
+	groupKey string
+	keys     []string
+	run      func() error
+}
+
+func newBackgroundRefresher(opts Options) *backgroundRefresher {
+	workers := opts.BackgroundRefreshWorkers
+	if workers <= 0 {
+		workers = defaultBackgroundRefreshWorkers
+	}
+	queueSize := opts.BackgroundRefreshQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultBackgroundRefreshQueueSize
+	}
+	return &backgroundRefresher{
+		workers:  workers,
+		tasks:    make(chan refreshTask, queueSize),
+		onErr:    opts.OnBackgroundRefreshError,
+		inFlight: map[string]struct{}{},
+	}
+}
+
+// submit queues the refresh unless the same keys are already being refreshed.
+// The refresh is dropped with ErrRefreshQueueFull reported if the queue is full.
+func (r *backgroundRefresher) submit(keys []string, run func() error) {
+	task := refreshTask{
+		groupKey: loadGroupKey(keys),
+		keys:     keys,
+		run:      run,
+	}
+	if !r.enqueue(task) {
+		// the callback is called without the lock as it might trigger reads submitting refreshes
+		r.reportErr(keys, ErrRefreshQueueFull)
+	}
+}
+
+// enqueue returns false only if the task is dropped because the queue is full
+func (r *backgroundRefresher) enqueue(task refreshTask) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return true
+	}
+	if _, ok := r.inFlight[task.groupKey]; ok {
+		return true
+	}
+	r.startOnce.Do(r.start)
+	select {
+	case r.tasks <- task:
+		r.inFlight[task.groupKey] = struct{}{}
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *backgroundRefresher) start() {
+	r.wg.Add(r.workers)
+	for i := 0; i < r.workers; i++ {
+		go func() {
+			defer r.wg.Done()
+			for task := range r.tasks {
+				if err := task.run(); err != nil {
+					r.reportErr(task.keys, err)
+				}
+				r.mu.Lock()
+				delete(r.inFlight, task.groupKey)
+				r.mu.Unlock()
+			}
+		}()
+	}
+}
+
+// close stops accepting new refreshes and waits for the queued ones
+func (r *backgroundRefresher) close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.tasks)
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func (r *backgroundRefresher) reportErr(keys []string, err error) {
+	if r.onErr != nil {
+		r.onErr(keys, err)
+	}
+}
+
+// refreshInBackground reloads the stale keys with the absent keys loader if there is one
+func refreshInBackground(ctx context.Context, opts Options, reader keysReader, staleKeys []KeyField) {
+	if opts.refresher == nil || !opts.hasAbsentKeysLoader() {
+		return
+	}
+	keys := make([]KeyField, len(staleKeys))
+	copy(keys, staleKeys)
+	refreshCtx := detachedContext{parent: ctx}
+	opts.refresher.submit(reader.errKeys(keys), func() error {
+		_, err := loadAndCacheAbsentKeys(refreshCtx, opts, reader, keys)
+		return err
+	})
+}
+
+// detachedContext keeps the values of the parent context, but not its deadline and cancellation,
+// as background refreshes outlive the calls they are started from
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
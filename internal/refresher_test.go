@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	requireLib "github.com/stretchr/testify/require"
+)
+
+func TestBackgroundRefresher_ErrCallbackMightSubmitRefreshes(t *testing.T) {
+	require := requireLib.New(t)
+	release := make(chan struct{})
+	var reported int32
+	var r *backgroundRefresher
+	r = newBackgroundRefresher(Options{
+		BackgroundRefreshWorkers:   1,
+		BackgroundRefreshQueueSize: 1,
+		OnBackgroundRefreshError: func(keys []string, err error) {
+			// a read from the callback might find stale keys again
+			if atomic.AddInt32(&reported, 1) == 1 {
+				r.submit([]string{"from-callback"}, func() error { return nil })
+			}
+		},
+	})
+	started := make(chan struct{})
+	r.submit([]string{"blocking"}, func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	r.submit([]string{"queued"}, func() error { return nil })
+
+	submitted := make(chan struct{})
+	go func() {
+		r.submit([]string{"dropped"}, func() error { return nil })
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+	case <-time.After(time.Second):
+		require.Fail("submit from the error callback must not deadlock")
+	}
+	require.EqualValues(2, atomic.LoadInt32(&reported), "both dropped refreshes must be reported")
+	close(release)
+	r.close()
+}
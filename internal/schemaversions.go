@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Migration converts a value stored with an older schema version into dst.
+// payload is the value as it was marshalled, dst is a pointer to the currently registered type.
+// storedVersion is zero for the values stored before the type was registered
+type Migration func(storedVersion uint32, payload []byte, dst interface{}) error
+
+// SchemaVersions registers the schema versions of the cached Go types.
+// The values of the registered types are stored along with their versions.
+// On reading a value with an older version is migrated via the migration registered for its version
+// or treated as a cache miss, so it's reloaded by the absent keys loader.
+// A value with a newer version, e.g. stored by the next release during its rollout, fails with ErrNewerSchemaVersion
+type SchemaVersions struct {
+	mu         sync.RWMutex
+	versions   map[reflect.Type]uint32
+	migrations map[reflect.Type]map[uint32]Migration
+}
+
+func NewSchemaVersions() *SchemaVersions {
+	return &SchemaVersions{
+		versions:   map[reflect.Type]uint32{},
+		migrations: map[reflect.Type]map[uint32]Migration{},
+	}
+}
+
+// Register sets the current schema version for the type of sample.
+// The pointers are dereferenced, so User{} and &User{} register the same type.
+// Zero version matches the values stored without a version
+func (s *SchemaVersions) Register(sample interface{}, version uint32) *SchemaVersions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[schemaType(sample)] = version
+	return s
+}
+
+// RegisterMigration sets the migration for the values of the sample type stored with fromVersion
+func (s *SchemaVersions) RegisterMigration(sample interface{}, fromVersion uint32, migration Migration) *SchemaVersions {
+	t := schemaType(sample)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.migrations[t] == nil {
+		s.migrations[t] = map[uint32]Migration{}
+	}
+	s.migrations[t][fromVersion] = migration
+	return s
+}
+
+// versionOf returns the current version of the value type, ok is false if the type isn't registered
+func (s *SchemaVersions) versionOf(val interface{}) (version uint32, ok bool) {
+	if s == nil {
+		return 0, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	version, ok = s.versions[schemaType(val)]
+	return version, ok
+}
+
+func (s *SchemaVersions) migration(val interface{}, fromVersion uint32) Migration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.migrations[schemaType(val)][fromVersion]
+}
+
+// schemaType strips the pointers, as the values are set by value or by pointer and read into pointers
+func schemaType(val interface{}) reflect.Type {
+	t := reflect.TypeOf(val)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -26,32 +26,133 @@ func SetKV(ctx context.Context, opts Options, keyValPairs ...interface{}) error
 	return SetMulti(ctx, opts, items...)
 }
 
-func SetMulti(ctx context.Context, opts Options, items ...*Item) (err error) {
+func SetMulti(ctx context.Context, opts Options, items ...*Item) error {
+	_, err := SetMultiWithResults(ctx, opts, items...)
+	return err
+}
+
+// SetMultiWithResults stores the items and returns the outcome for every item in the same order.
+// No results are returned if some of the items can't be marshalled: nothing is stored then
+func SetMultiWithResults(ctx context.Context, opts Options, items ...*Item) ([]SetResult, error) {
+	results, setErr := setMulti(ctx, opts, false, items)
+	var msg invalidationMessage
+	for _, item := range items {
+		msg.addKeyAndField(item.Key, item.Field)
+	}
+	publishErr := publishInvalidation(ctx, opts, &msg)
+	if setErr != nil {
+		return results, setErr
+	}
+	return results, publishErr
+}
+
+// setMulti stores the items in Redis.
+// If keepLocally is true the written items are put into the local cache, otherwise they are removed from there.
+// The failures are returned as SetErr.
+func setMulti(ctx context.Context, opts Options, keepLocally bool, items []*Item) (results []SetResult, err error) {
 	if len(items) == 0 {
-		return nil
+		return nil, nil
 	}
-	r := opts.Redis
-	var pipeliner redis.Pipeliner
-	if len(items) > 1 || items[0].Field != "" {
-		pipeliner = opts.Redis.Pipeline()
-		r = pipeliner
+	marshalled, marshalErr := marshalItems(opts, items)
+	if marshalErr != nil {
+		for _, item := range items {
+			opts.removeLocally(item.Key, item.Field)
+		}
+		return nil, marshalErr
 	}
-	for _, item := range items {
-		err = setOne(ctx, opts, r, item)
+	return setMarshalled(ctx, opts, keepLocally, items, marshalled)
+}
+
+// marshalItems marshals the values of the items the way they are stored
+func marshalItems(opts Options, items []*Item) ([][]byte, error) {
+	marshalled := make([][]byte, len(items))
+	for idx, item := range items {
+		var err error
+		marshalled[idx], err = opts.marshal(item)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
-	if pipeliner != nil {
-		_, err = pipeliner.Exec(ctx)
+	return marshalled, nil
+}
+
+// setMarshalled stores the items which values are already marshalled via marshalItems, see setMulti
+func setMarshalled(ctx context.Context, opts Options, keepLocally bool, items []*Item, marshalled [][]byte) (results []SetResult, err error) {
+	defer func() {
+		for idx, item := range items {
+			if keepLocally && results != nil && results[idx].Outcome == SetWritten {
+				opts.cacheLocally(item.Key, item.Field, string(marshalled[idx]))
+			} else {
+				opts.removeLocally(item.Key, item.Field)
+			}
+		}
+	}()
+	results = make([]SetResult, len(items))
+	if len(items) == 1 && items[0].Field == "" {
+		results[0] = itemSetResult(items[0], []redis.Cmder{writePlainItem(ctx, opts, opts.Redis, items[0], marshalled[0])})
+		return results, asSetErr(results)
 	}
-	return err
+	itemsCmds := make([][]redis.Cmder, len(items))
+	itemIdxs := make([]int, len(items))
+	for idx := range items {
+		itemIdxs[idx] = idx
+	}
+	itemKey := func(idx int) string {
+		return items[idx].Key
+	}
+	// the server is probed for the native hash map fields expiration before filling the pipelines
+	var nativeFieldTTL bool
+	for _, item := range items {
+		if item.Field != "" {
+			nativeFieldTTL = opts.nativeHashFieldTTL(ctx)
+			break
+		}
+	}
+	fill := func(loadScripts bool) func(pipeliner redis.Pipeliner, itemIdxs []int) []redis.Cmder {
+		return func(pipeliner redis.Pipeliner, itemIdxs []int) []redis.Cmder {
+			var cmds []redis.Cmder
+			for _, idx := range itemIdxs {
+				itemsCmds[idx] = writeItem(ctx, opts, pipeliner, items[idx], marshalled[idx], loadScripts, nativeFieldTTL)
+				cmds = append(cmds, itemsCmds[idx]...)
+			}
+			return cmds
+		}
+	}
+	itemCmdsCount := func(idx int) int {
+		return opts.itemCmdsCount(items[idx])
+	}
+	execWeightedPipelined(ctx, opts, itemIdxs, itemKey, itemCmdsCount, fill(false))
+	// the scripts are sent once more via EVAL for the Redis nodes which don't have them loaded yet
+	var noScriptIdxs []int
+	for idx, cmds := range itemsCmds {
+		if isNoScriptErr(cmds[0].Err()) {
+			noScriptIdxs = append(noScriptIdxs, idx)
+		}
+	}
+	if len(noScriptIdxs) > 0 {
+		execWeightedPipelined(ctx, opts, noScriptIdxs, itemKey, itemCmdsCount, fill(true))
+	}
+	for idx, item := range items {
+		results[idx] = itemSetResult(item, itemsCmds[idx])
+	}
+	return results, asSetErr(results)
+}
+
+// asSetErr returns SetErr for the failed results or nil avoiding a typed nil error
+func asSetErr(results []SetResult) error {
+	if setErr := setResultsErr(results); setErr != nil {
+		return setErr
+	}
+	return nil
 }
 
 func HSetKV(ctx context.Context, opts Options, key string, fieldValPairs ...interface{}) error {
 	if len(fieldValPairs)%2 != 0 {
 		return ErrKeyPairs
 	}
+	if opts.AtomicHashWrites || opts.HashFieldTTL {
+		return hSetKVAtomically(ctx, opts, key, fieldValPairs)
+	}
 	fieldMarshalledValsPairs := make([]interface{}, len(fieldValPairs))
 	for idx := 0; idx < len(fieldValPairs); idx += 2 {
 		// @todo allow string subtypes here as well
@@ -59,46 +160,146 @@ func HSetKV(ctx context.Context, opts Options, key string, fieldValPairs ...inte
 		if !ok {
 			return errors.Wrapf(ErrNonStringKey, "string field expected for position %d, `%#+v` of type %T given", idx, fieldValPairs[idx], fieldValPairs[idx])
 		}
-		marshalledBytes, marshalErr := opts.Marshaller.Marshal(fieldValPairs[idx+1])
+		marshalledBytes, marshalErr := opts.marshal(&Item{
+			Key:   key,
+			Field: field,
+			Value: fieldValPairs[idx+1],
+			TTL:   opts.DefaultTTL,
+		})
 		if marshalErr != nil {
 			return marshalErr
 		}
 		fieldMarshalledValsPairs[idx] = field
 		fieldMarshalledValsPairs[idx+1] = string(marshalledBytes)
 	}
-	pipeline := opts.Redis.Pipeline()
-	pipeline.HSet(ctx, key, fieldMarshalledValsPairs...)
-	pipeline.Expire(ctx, key, opts.DefaultTTL)
-	_, pipelineErr := pipeline.Exec(ctx)
-	return pipelineErr
+	var msg invalidationMessage
+	for idx := 0; idx < len(fieldMarshalledValsPairs); idx += 2 {
+		field := fieldMarshalledValsPairs[idx].(string)
+		opts.removeLocally(key, field)
+		msg.addKeyAndField(key, field)
+	}
+	// every field is counted as a command and EXPIRE is added to every chunk
+	if opts.MaxPipelineCommands > 1 {
+		opts.MaxPipelineCommands--
+	}
+	pairIdxs := make([]int, 0, len(fieldMarshalledValsPairs)/2)
+	for idx := 0; idx < len(fieldMarshalledValsPairs); idx += 2 {
+		pairIdxs = append(pairIdxs, idx)
+	}
+	cmds := execPipelined(
+		ctx,
+		opts,
+		pairIdxs,
+		func(int) string {
+			return key
+		},
+		func(pipeliner redis.Pipeliner, pairIdxs []int) []redis.Cmder {
+			chunkPairs := make([]interface{}, 0, 2*len(pairIdxs))
+			for _, idx := range pairIdxs {
+				chunkPairs = append(chunkPairs, fieldMarshalledValsPairs[idx], fieldMarshalledValsPairs[idx+1])
+			}
+			return []redis.Cmder{
+				pipeliner.HSet(ctx, key, chunkPairs...),
+				pipeliner.Expire(ctx, key, opts.DefaultTTL),
+			}
+		},
+	)
+	publishErr := publishInvalidation(ctx, opts, &msg)
+	if setErr := hsetCmdsErr(cmds); setErr != nil {
+		return setErr
+	}
+	return publishErr
 }
 
-func setOne(ctx context.Context, opts Options, rediser Rediser, item *Item) error {
-	b, marshalErr := opts.Marshaller.Marshal(item.Value)
-	if marshalErr != nil {
-		return marshalErr
+// hSetKVAtomically writes every field along with the key TTL via hashWriteScript
+func hSetKVAtomically(ctx context.Context, opts Options, key string, fieldValPairs []interface{}) error {
+	items := make([]*Item, 0, len(fieldValPairs)/2)
+	for idx := 0; idx < len(fieldValPairs); idx += 2 {
+		field, ok := fieldValPairs[idx].(string)
+		if !ok {
+			return errors.Wrapf(ErrNonStringKey, "string field expected for position %d, `%#+v` of type %T given", idx, fieldValPairs[idx], fieldValPairs[idx])
+		}
+		items = append(items, &Item{
+			Key:   key,
+			Field: field,
+			Value: fieldValPairs[idx+1],
+			TTL:   opts.DefaultTTL,
+		})
 	}
+	return SetMulti(ctx, opts, items...)
+}
 
-	ttl := opts.redisTTL(item.TTL)
+// hsetCmdsErr maps the failed HSET commands to the fields and the failed EXPIRE commands to the keys
+func hsetCmdsErr(cmds []redis.Cmder) *SetErr {
+	setErr := &SetErr{}
+	for _, cmd := range cmds {
+		err := cmd.Err()
+		if err == nil {
+			continue
+		}
+		key := cmdKey(cmd)
+		if cmd.Name() == "expire" {
+			setErr.addExpireError(key, err)
+			continue
+		}
+		args := cmd.Args()
+		for idx := 2; idx < len(args); idx += 2 {
+			field, _ := args[idx].(string)
+			setErr.addErrorForKeyAndField(key, field, err)
+		}
+	}
+	if setErr.isEmpty() {
+		return nil
+	}
+	return setErr
+}
+
+// itemCmdsCount returns the number of the commands writeItem adds for the item
+func (opt Options) itemCmdsCount(item *Item) int {
+	if item.Field == "" || opt.writesHashAtomically(item) {
+		return 1
+	}
+	return 2
+}
 
+// writeItem adds the commands storing the marshalled item value.
+// The writing command goes first, the EXPIRE for hash maps follows it.
+// The hash map items might be written via a script, loadScripts makes it sent via EVAL instead of EVALSHA.
+// nativeFieldTTL is set if the server supports HPEXPIRE (see nativeHashFieldTTL)
+func writeItem(
+	ctx context.Context,
+	opts Options,
+	pipeliner redis.Pipeliner,
+	item *Item,
+	b []byte,
+	loadScripts, nativeFieldTTL bool,
+) []redis.Cmder {
 	if item.Field == "" {
+		return []redis.Cmder{writePlainItem(ctx, opts, pipeliner, item, b)}
+	}
+	if opts.writesHashAtomically(item) {
+		return []redis.Cmder{writeHashItemAtomically(ctx, opts, pipeliner, item, b, loadScripts, nativeFieldTTL)}
+	}
+	ttl := opts.redisTTL(item.TTL)
+	var setCmd redis.Cmder
+	if item.IfNotExists {
+		setCmd = pipeliner.HSetNX(ctx, item.Key, item.Field, string(b))
+	} else {
+		setCmd = pipeliner.HSet(ctx, item.Key, item.Field, string(b))
+	}
+	return []redis.Cmder{setCmd, pipeliner.Expire(ctx, item.Key, ttl)}
+}
 
-		if item.IfExists {
-			return rediser.SetXX(ctx, item.Key, b, ttl).Err()
-		}
+func writePlainItem(ctx context.Context, opts Options, rediser Rediser, item *Item, b []byte) redis.Cmder {
+	ttl := opts.redisTTL(item.TTL)
 
-		if item.IfNotExists {
-			return rediser.SetNX(ctx, item.Key, b, ttl).Err()
-		}
+	if item.IfExists {
+		return rediser.SetXX(ctx, item.Key, b, ttl)
+	}
 
-		return rediser.Set(ctx, item.Key, b, ttl).Err()
-	} else {
-		if item.IfNotExists {
-			rediser.HSetNX(ctx, item.Key, item.Field, string(b))
-		} else {
-			rediser.HSet(ctx, item.Key, item.Field, string(b))
-		}
-		rediser.Expire(ctx, item.Key, ttl)
+	if item.IfNotExists {
+		return rediser.SetNX(ctx, item.Key, b, ttl)
 	}
-	return nil
+
+	return rediser.Set(ctx, item.Key, b, ttl)
 }
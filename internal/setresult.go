@@ -0,0 +1,100 @@
+package internal
+
+import "github.com/go-redis/redis/v8"
+
+// SetOutcome describes what happened to an item on setting
+type SetOutcome int
+
+const (
+	// SetWritten means the item is stored
+	SetWritten SetOutcome = iota
+	// SetSkippedExists means the item with IfNotExists isn't stored as the key or the field already exists
+	SetSkippedExists
+	// SetSkippedMissing means the item with IfExists isn't stored as the key doesn't exist
+	SetSkippedMissing
+	// SetFailed means storing the item failed, see SetResult.Err
+	SetFailed
+)
+
+func (o SetOutcome) String() string {
+	switch o {
+	case SetWritten:
+		return "written"
+	case SetSkippedExists:
+		return "skipped: exists"
+	case SetSkippedMissing:
+		return "skipped: missing"
+	case SetFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// SetResult is the outcome of setting a single item
+type SetResult struct {
+	Item    *Item
+	Outcome SetOutcome
+	// Err is set for the SetFailed outcome only
+	Err error
+	// ExpireErr is set if the hash map field is written, but the TTL of the key couldn't be set
+	ExpireErr error
+}
+
+// itemSetResult converts the results of the commands storing the item into its outcome.
+// The writing command goes first, the optional EXPIRE follows it
+func itemSetResult(item *Item, cmds []redis.Cmder) SetResult {
+	res := SetResult{Item: item, Outcome: SetWritten}
+	if len(cmds) == 0 {
+		return res
+	}
+	if err := cmds[0].Err(); err != nil && err != redis.Nil {
+		res.Outcome = SetFailed
+		res.Err = err
+		return res
+	}
+	for _, cmd := range cmds[1:] {
+		if err := cmd.Err(); err != nil && res.ExpireErr == nil {
+			res.ExpireErr = err
+		}
+	}
+	if !isWritten(cmds[0]) {
+		switch {
+		case item.IfNotExists:
+			res.Outcome = SetSkippedExists
+		case item.IfExists:
+			res.Outcome = SetSkippedMissing
+		}
+	}
+	return res
+}
+
+// isWritten checks the result of the conditional commands and the hash map writing script
+func isWritten(cmd redis.Cmder) bool {
+	switch cmd := cmd.(type) {
+	case *redis.BoolCmd:
+		return cmd.Val()
+	case *redis.Cmd:
+		written, _ := cmd.Int64()
+		return written == 1
+	default:
+		return true
+	}
+}
+
+// setResultsErr collects the failures of the results, nil is returned if there are none
+func setResultsErr(results []SetResult) *SetErr {
+	setErr := &SetErr{}
+	for _, res := range results {
+		if res.Err != nil {
+			setErr.addErrorForKeyAndField(res.Item.Key, res.Item.Field, res.Err)
+		}
+		if res.ExpireErr != nil {
+			setErr.addExpireError(res.Item.Key, res.ExpireErr)
+		}
+	}
+	if setErr.isEmpty() {
+		return nil
+	}
+	return setErr
+}
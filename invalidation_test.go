@@ -0,0 +1,134 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/localcache"
+)
+
+type InvalidationSuite struct {
+	BaseCacheSuite
+	channel     string
+	writer      *cache.Cache
+	readerLocal *localcache.Cache
+	reader      *cache.Cache
+	subscriber  *cache.InvalidationSubscriber
+}
+
+func (st *InvalidationSuite) SetupTest() {
+	st.channel = "invalidation-" + faker.RandomString(8)
+	st.writer = cache.NewCache(cache.Options{
+		Redis:               st.client,
+		Marshaller:          st.marshaller,
+		LocalCache:          localcache.New(localcache.Options{}),
+		InvalidationChannel: st.channel,
+	})
+	st.readerLocal = localcache.New(localcache.Options{})
+	st.reader = cache.NewCache(cache.Options{
+		Redis:               st.client,
+		Marshaller:          st.marshaller,
+		LocalCache:          st.readerLocal,
+		InvalidationChannel: st.channel,
+	})
+	st.subscriber = cache.SubscribeInvalidations(st.ctx, st.client, st.channel, st.readerLocal, func(err error) {
+		st.Failf("unexpected subscriber error", "%+v", err)
+	})
+	// make sure the subscription is active before publishing anything
+	st.Require().Eventually(func() bool {
+		channels, err := st.client.PubSubNumSub(st.ctx, st.channel).Result()
+		return err == nil && channels[st.channel] > 0
+	}, time.Second, 10*time.Millisecond, "subscription expected")
+}
+
+func (st *InvalidationSuite) TearDownTest() {
+	st.Require().NoError(st.subscriber.Close(), "No error expected on closing the subscriber")
+}
+
+func (st *InvalidationSuite) requireEvicted(key, field string) {
+	st.T().Helper()
+	st.Require().Eventuallyf(func() bool {
+		_, ok := st.readerLocal.Get(key, field)
+		return !ok
+	}, time.Second, 10*time.Millisecond, "%q/%q must be evicted from the reader local cache", key, field)
+}
+
+func (st *InvalidationSuite) TestSetEvictsKeysOnOtherInstances() {
+	key := faker.RandomString(7)
+	st.Require().NoError(st.cache.SetKV(st.ctx, key, "old"), "No error expected on setting")
+	var dst string
+	st.Require().NoError(st.reader.Get(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Equal("old", dst)
+
+	st.Require().NoError(st.writer.SetKV(st.ctx, key, "new"), "No error expected on setting")
+	st.requireEvicted(key, "")
+	st.Require().NoError(st.reader.Get(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Equal("new", dst, "the new value expected on the reader")
+}
+
+func (st *InvalidationSuite) TestHashFieldsAndDeletesAreEvicted() {
+	key := faker.RandomString(7)
+	st.Require().NoError(st.cache.HSetKV(st.ctx, key, "f1", "v1", "f2", "v2"), "No error expected on setting")
+	var dst map[string]string
+	st.Require().NoError(st.reader.HGetFieldsForKey(st.ctx, &dst, key, "f1", "f2"), "No error expected on getting")
+
+	st.Require().NoError(
+		st.writer.Set(st.ctx, &cache.Item{Key: key, Field: "f1", Value: "v1-new"}),
+		"No error expected on setting",
+	)
+	st.requireEvicted(key, "f1")
+	_, ok := st.readerLocal.Get(key, "f2")
+	st.Require().True(ok, "other fields must be kept")
+
+	st.Require().NoError(st.writer.Delete(st.ctx, key), "No error expected on deleting")
+	st.requireEvicted(key, "f2")
+}
+
+// rediser lists only the methods cache.Options.Redis requires
+type rediser interface {
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	SetXX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Get(ctx context.Context, key string) *redis.StringCmd
+	HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd
+	HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd
+	HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
+	HSetNX(ctx context.Context, key, field string, value interface{}) *redis.BoolCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Pipeline() redis.Pipeliner
+}
+
+// minimalRediser hides the rest methods of the wrapped client, e.g. Publish
+type minimalRediser struct {
+	rediser
+}
+
+func (st *InvalidationSuite) TestPublishIsRequiredOnlyForInvalidationChannel() {
+	key := faker.RandomString(7)
+	withoutChannel := cache.NewCache(cache.Options{
+		Redis:      minimalRediser{st.client},
+		Marshaller: st.marshaller,
+	})
+	st.Require().NoError(withoutChannel.SetKV(st.ctx, key, "val"), "Publish mustn't be required without InvalidationChannel")
+
+	withChannel := cache.NewCache(cache.Options{
+		Redis:               minimalRediser{st.client},
+		Marshaller:          st.marshaller,
+		InvalidationChannel: st.channel,
+	})
+	setErr := withChannel.SetKV(st.ctx, key, "val")
+	st.Require().True(errors.Is(setErr, cache.ErrPublishNotSupported), "ErrPublishNotSupported expected, %+v given", setErr)
+}
+
+func TestInvalidationSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &InvalidationSuite{})
+}
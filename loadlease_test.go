@@ -0,0 +1,172 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+type LoadLeaseSuite struct {
+	BaseCacheSuite
+}
+
+const testLeaseKeyPrefix = "test-lease:"
+
+func (st *LoadLeaseSuite) leasedCache(lease cache.LoadLeaseOptions) *cache.Cache {
+	return cache.NewCache(cache.Options{
+		Redis:      st.client,
+		Marshaller: st.marshaller,
+		LoadLease:  lease,
+	})
+}
+
+func (st *LoadLeaseSuite) TestOnlyLeaseHolderLoads() {
+	const instances = 5
+	keysToLoad := []string{faker.RandomString(8), faker.RandomString(8)}
+
+	var loaderCalls int32
+	var wg sync.WaitGroup
+	dsts := make([]map[string]string, instances)
+	errs := make([]error, instances)
+	for i := 0; i < instances; i++ {
+		// every instance has its own cache as it would be in different processes
+		instanceCache := st.leasedCache(cache.LoadLeaseOptions{TTL: 5 * time.Second}).
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				atomic.AddInt32(&loaderCalls, 1)
+				time.Sleep(100 * time.Millisecond)
+				return st.keysToMap(absentKeys...), nil
+			})
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			errs[idx] = instanceCache.Get(st.ctx, &dsts[idx], keysToLoad...)
+		}(i)
+	}
+	wg.Wait()
+
+	st.Require().EqualValues(1, atomic.LoadInt32(&loaderCalls), "only the lease holder must call the loader")
+	for i := 0; i < instances; i++ {
+		st.Require().NoError(errs[i], "No error expected for instance %d", i)
+		st.Require().EqualValues(st.keysToMap(keysToLoad...), dsts[i], "unexpected loaded items for instance %d", i)
+	}
+	for _, k := range keysToLoad {
+		// the reserved prefix is used by default
+		leaseErr := st.client.Get(st.ctx, "\x00lease:"+k).Err()
+		st.Require().Truef(errors.Is(leaseErr, redis.Nil), "lease for %q must be released, %+v given", k, leaseErr)
+	}
+}
+
+func (st *LoadLeaseSuite) TestWaitsForValueLoadedByLeaseHolder() {
+	key := faker.RandomString(8)
+	st.Require().NoError(
+		st.client.SetNX(st.ctx, testLeaseKeyPrefix+key, "other-holder", 5*time.Second).Err(),
+		"No error expected on taking the lease",
+	)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = st.cache.SetKV(st.ctx, key, "loaded by the other holder")
+	}()
+
+	var dst string
+	st.Require().NoError(
+		st.leasedCache(cache.LoadLeaseOptions{TTL: 5 * time.Second, WaitTimeout: 2 * time.Second, KeyPrefix: testLeaseKeyPrefix}).
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				st.Fail("the loader mustn't be called while the lease is held by another process")
+				return nil, nil
+			}).
+			Get(st.ctx, &dst, key),
+		"No error expected",
+	)
+	st.Require().Equal("loaded by the other holder", dst, "the value cached by the lease holder expected")
+}
+
+func (st *LoadLeaseSuite) TestWaiterReportsKeysFoundAbsentByLeaseHolder() {
+	key := faker.RandomString(8)
+	st.Require().NoError(
+		st.client.SetNX(st.ctx, testLeaseKeyPrefix+key, "other-holder", 5*time.Second).Err(),
+		"No error expected on taking the lease",
+	)
+	negativeCacheOpts := cache.Options{
+		Redis:       st.client,
+		Marshaller:  st.marshaller,
+		NegativeTTL: 5 * time.Second,
+	}
+	// the lease holder doesn't find the key and stores its tombstone
+	holderCache := cache.NewCache(negativeCacheOpts).WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		return nil, nil
+	})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		var holderDst string
+		_ = holderCache.Get(st.ctx, &holderDst, key)
+	}()
+
+	negativeCacheOpts.LoadLease = cache.LoadLeaseOptions{TTL: 5 * time.Second, WaitTimeout: 2 * time.Second, KeyPrefix: testLeaseKeyPrefix}
+	var dst string
+	loadErr := cache.NewCache(negativeCacheOpts).
+		WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+			st.Fail("the loader mustn't be called while the lease is held by another process")
+			return nil, nil
+		}).
+		Get(st.ctx, &dst, key)
+	st.Require().Equal(cache.ErrKnownAbsent, loadErr, "the key found absent by the lease holder must be reported")
+}
+
+func (st *LoadLeaseSuite) TestLoadsItselfAfterWaitTimeout() {
+	key := faker.RandomString(8)
+	field := faker.RandomString(4)
+	st.Require().NoError(
+		st.client.SetNX(st.ctx, testLeaseKeyPrefix+cachekeys.KeyWithField(key, field), "other-holder", 5*time.Second).Err(),
+		"No error expected on taking the lease",
+	)
+
+	var loaderCalls int32
+	var dst map[string]string
+	startedAt := time.Now()
+	st.Require().NoError(
+		st.leasedCache(cache.LoadLeaseOptions{TTL: 5 * time.Second, WaitTimeout: 100 * time.Millisecond, KeyPrefix: testLeaseKeyPrefix}).
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				atomic.AddInt32(&loaderCalls, 1)
+				return st.keysToMap(absentKeys...), nil
+			}).
+			HGetFieldsForKey(st.ctx, &dst, key, field),
+		"No error expected",
+	)
+	st.Require().GreaterOrEqual(int64(time.Since(startedAt)), int64(100*time.Millisecond), "the wait timeout must pass before loading")
+	st.Require().EqualValues(1, atomic.LoadInt32(&loaderCalls), "the loader must be called after the wait timeout")
+	st.Require().EqualValues(st.keysToMap(cachekeys.KeyWithField(key, field)), dst, "unexpected loaded items")
+}
+
+func (st *LoadLeaseSuite) TestLeaseIsReleasedAfterCancellation() {
+	key := faker.RandomString(8)
+	ctx, cancel := context.WithCancel(st.ctx)
+	defer cancel()
+
+	var dst string
+	loadErr := st.leasedCache(cache.LoadLeaseOptions{TTL: 5 * time.Second, KeyPrefix: testLeaseKeyPrefix}).
+		WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+			// the call is cancelled while the keys are being loaded
+			cancel()
+			return st.keysToMap(absentKeys...), nil
+		}).
+		Get(ctx, &dst, key)
+	st.Require().True(errors.Is(loadErr, context.Canceled), "cancellation error expected, %+v given", loadErr)
+
+	leaseErr := st.client.Get(st.ctx, testLeaseKeyPrefix+key).Err()
+	st.Require().Truef(errors.Is(leaseErr, redis.Nil), "lease for %q must be released, %+v given", key, leaseErr)
+}
+
+func TestLoadLeaseSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &LoadLeaseSuite{})
+}
@@ -0,0 +1,166 @@
+package localcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxEntries = 10000
+	DefaultTTL        = 1 * time.Minute
+)
+
+// Options configures the local cache bounds
+type Options struct {
+	// MaxEntries limits the number of stored entries.
+	// DefaultMaxEntries is used if it isn't positive
+	MaxEntries int
+
+	// MaxBytes limits the total size of stored keys, fields and values.
+	// There is no limit by size if it isn't positive
+	MaxBytes int
+
+	// TTL is the lifetime of an entry in the local cache.
+	// It's expected to be much shorter than the Redis TTL.
+	// DefaultTTL is used if it isn't positive
+	TTL time.Duration
+}
+
+// Cache is an in-process LRU cache of marshalled values.
+// It stores plain keys (with an empty field) and hash map key/field pairs
+// and is safe for concurrent use.
+type Cache struct {
+	opt Options
+	now func() time.Time
+
+	mu      sync.Mutex
+	size    int
+	ll      *list.List
+	entries map[entryKey]*list.Element
+	// fields keeps all the fields stored for a key to drop them together with the key
+	fields map[string]map[string]struct{}
+}
+
+type entryKey struct {
+	key   string
+	field string
+}
+
+type entry struct {
+	entryKey
+	val       []byte
+	expiresAt time.Time
+}
+
+func (e *entry) size() int {
+	return len(e.key) + len(e.field) + len(e.val)
+}
+
+func New(opt Options) *Cache {
+	if opt.MaxEntries <= 0 {
+		opt.MaxEntries = DefaultMaxEntries
+	}
+	if opt.TTL <= 0 {
+		opt.TTL = DefaultTTL
+	}
+	return &Cache{
+		opt:     opt,
+		now:     time.Now,
+		ll:      list.New(),
+		entries: map[entryKey]*list.Element{},
+		fields:  map[string]map[string]struct{}{},
+	}
+}
+
+// Get returns the value stored for the key and the field.
+// The field is empty for non-hash keys
+func (c *Cache) Get(key, field string) (val []byte, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[entryKey{key: key, field: field}]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expiresAt) {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.val, true
+}
+
+// Set stores the value for the key and the field evicting the least recently used entries if needed
+func (c *Cache) Set(key, field string, val []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ek := entryKey{key: key, field: field}
+	if el, ok := c.entries[ek]; ok {
+		c.removeElement(el)
+	}
+	e := &entry{
+		entryKey:  ek,
+		val:       val,
+		expiresAt: c.now().Add(c.opt.TTL),
+	}
+	if c.opt.MaxBytes > 0 && e.size() > c.opt.MaxBytes {
+		return
+	}
+	c.entries[ek] = c.ll.PushFront(e)
+	c.size += e.size()
+	if c.fields[key] == nil {
+		c.fields[key] = map[string]struct{}{}
+	}
+	c.fields[key][field] = struct{}{}
+
+	for c.ll.Len() > c.opt.MaxEntries || (c.opt.MaxBytes > 0 && c.size > c.opt.MaxBytes) {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Delete removes the value stored for the key and the field
+func (c *Cache) Delete(key, field string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[entryKey{key: key, field: field}]; ok {
+		c.removeElement(el)
+	}
+}
+
+// DeleteKey removes the key together with all its hash map fields
+func (c *Cache) DeleteKey(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for field := range c.fields[key] {
+		c.removeElement(c.entries[entryKey{key: key, field: field}])
+	}
+}
+
+// Flush removes all the entries
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.size = 0
+	c.entries = map[entryKey]*list.Element{}
+	c.fields = map[string]map[string]struct{}{}
+}
+
+// Len returns the number of stored entries, including expired but not yet evicted ones
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *Cache) removeElement(el *list.Element) {
+	e := c.ll.Remove(el).(*entry)
+	c.size -= e.size()
+	delete(c.entries, e.entryKey)
+	keyFields := c.fields[e.key]
+	delete(keyFields, e.field)
+	if len(keyFields) == 0 {
+		delete(c.fields, e.key)
+	}
+}
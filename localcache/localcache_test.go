@@ -0,0 +1,125 @@
+package localcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LocalCacheSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (st *LocalCacheSuite) newCache(opt Options) *Cache {
+	c := New(opt)
+	c.now = func() time.Time {
+		return st.now
+	}
+	return c
+}
+
+func (st *LocalCacheSuite) SetupTest() {
+	st.now = time.Now()
+}
+
+func (st *LocalCacheSuite) requireEntry(c *Cache, key, field, expected string) {
+	st.T().Helper()
+	val, ok := c.Get(key, field)
+	st.Require().Truef(ok, "entry %q/%q expected", key, field)
+	st.Require().Equal(expected, string(val), "unexpected value")
+}
+
+func (st *LocalCacheSuite) requireNoEntry(c *Cache, key, field string) {
+	st.T().Helper()
+	_, ok := c.Get(key, field)
+	st.Require().Falsef(ok, "entry %q/%q must be absent", key, field)
+}
+
+func (st *LocalCacheSuite) TestSetAndGet() {
+	c := st.newCache(Options{})
+	c.Set("k", "", []byte("v"))
+	c.Set("h", "f", []byte("hv"))
+
+	st.requireEntry(c, "k", "", "v")
+	st.requireEntry(c, "h", "f", "hv")
+	st.requireNoEntry(c, "h", "")
+	st.requireNoEntry(c, "k", "f")
+}
+
+func (st *LocalCacheSuite) TestEntriesExpire() {
+	c := st.newCache(Options{TTL: time.Second})
+	c.Set("k", "", []byte("v"))
+
+	st.now = st.now.Add(999 * time.Millisecond)
+	st.requireEntry(c, "k", "", "v")
+
+	st.now = st.now.Add(time.Millisecond)
+	st.requireNoEntry(c, "k", "")
+	st.Require().Zero(c.Len(), "expired entry must be removed")
+}
+
+func (st *LocalCacheSuite) TestEvictsLeastRecentlyUsedByEntries() {
+	c := st.newCache(Options{MaxEntries: 2})
+	c.Set("k1", "", []byte("v1"))
+	c.Set("k2", "", []byte("v2"))
+	// k1 becomes the most recently used one
+	st.requireEntry(c, "k1", "", "v1")
+	c.Set("k3", "", []byte("v3"))
+
+	st.requireNoEntry(c, "k2", "")
+	st.requireEntry(c, "k1", "", "v1")
+	st.requireEntry(c, "k3", "", "v3")
+}
+
+func (st *LocalCacheSuite) TestEvictsLeastRecentlyUsedByBytes() {
+	// every entry takes 4 bytes: 2 for the key and 2 for the value
+	c := st.newCache(Options{MaxBytes: 10})
+	c.Set("k1", "", []byte("v1"))
+	c.Set("k2", "", []byte("v2"))
+	c.Set("k3", "", []byte("v3"))
+
+	st.requireNoEntry(c, "k1", "")
+	st.requireEntry(c, "k2", "", "v2")
+	st.requireEntry(c, "k3", "", "v3")
+
+	c.Set("k4", "", []byte("too large value"))
+	st.requireNoEntry(c, "k4", "")
+	st.Require().Equal(2, c.Len(), "too large values mustn't evict others")
+}
+
+func (st *LocalCacheSuite) TestOverwriteReplacesEntry() {
+	c := st.newCache(Options{MaxBytes: 10})
+	c.Set("k1", "", []byte("v1"))
+	c.Set("k1", "", []byte("v11"))
+	st.requireEntry(c, "k1", "", "v11")
+	st.Require().Equal(1, c.Len(), "single entry expected")
+
+	c.Set("k1", "", []byte("too large value"))
+	st.requireNoEntry(c, "k1", "")
+}
+
+func (st *LocalCacheSuite) TestDelete() {
+	c := st.newCache(Options{})
+	c.Set("h", "f1", []byte("v1"))
+	c.Set("h", "f2", []byte("v2"))
+	c.Set("k", "", []byte("v"))
+
+	c.Delete("h", "f1")
+	st.requireNoEntry(c, "h", "f1")
+	st.requireEntry(c, "h", "f2", "v2")
+
+	c.DeleteKey("h")
+	st.requireNoEntry(c, "h", "f2")
+	st.requireEntry(c, "k", "", "v")
+
+	c.Flush()
+	st.requireNoEntry(c, "k", "")
+	st.Require().Zero(c.Len(), "no entries expected after flush")
+}
+
+func TestLocalCacheSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &LocalCacheSuite{})
+}
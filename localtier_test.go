@@ -0,0 +1,110 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+	"github.com/vkuptcov/go-redis-cache/v8/localcache"
+)
+
+type LocalTierSuite struct {
+	BaseCacheSuite
+	local       *localcache.Cache
+	tieredCache *cache.Cache
+}
+
+func (st *LocalTierSuite) SetupTest() {
+	st.local = localcache.New(localcache.Options{})
+	st.tieredCache = cache.NewCache(cache.Options{
+		Redis:      st.client,
+		Marshaller: st.marshaller,
+		LocalCache: st.local,
+	})
+}
+
+func (st *LocalTierSuite) TestGetIsServedLocallyAfterRedisHit() {
+	data := st.generateKeyValPairs()
+	st.Require().NoError(st.cache.SetKV(st.ctx, data.keyValPairs...), "No error expected on setting values")
+
+	var dst map[string]string
+	st.Require().NoError(st.tieredCache.Get(st.ctx, &dst, data.keys...), "No error expected on loading values")
+	st.Require().EqualValues(data.keyVals, dst, "unexpected values loaded from Redis")
+
+	// values removed from Redis directly are still served from the local tier
+	st.Require().NoError(st.client.Del(st.ctx, data.keys...).Err(), "No error expected on removing keys")
+	var localDst map[string]string
+	st.Require().NoError(st.tieredCache.Get(st.ctx, &localDst, data.keys...), "No error expected on loading values")
+	st.Require().EqualValues(data.keyVals, localDst, "unexpected values loaded from the local tier")
+}
+
+func (st *LocalTierSuite) TestHashFieldsAreServedLocally() {
+	key := faker.RandomString(7)
+	fieldVals := st.generateKeyValPairs()
+	st.Require().NoError(st.cache.HSetKV(st.ctx, key, fieldVals.keyValPairs...), "No error expected on setting fields")
+
+	var dst map[string]map[string]string
+	st.Require().NoError(st.tieredCache.HGetAll(st.ctx, &dst, key), "No error expected on loading the hash map")
+
+	st.Require().NoError(st.client.Del(st.ctx, key).Err(), "No error expected on removing the key")
+	var localDst map[string]map[string]string
+	st.Require().NoError(
+		st.tieredCache.HGetFieldsForKey(st.ctx, &localDst, key, fieldVals.keys...),
+		"No error expected on loading fields",
+	)
+	st.Require().EqualValues(map[string]map[string]string{key: fieldVals.keyVals}, localDst, "unexpected fields loaded from the local tier")
+}
+
+func (st *LocalTierSuite) TestLoadedItemsAreKeptLocally() {
+	key := faker.RandomString(7)
+	var dst string
+	st.Require().NoError(
+		st.tieredCache.
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				return st.keyToElement(absentKeys[0]), nil
+			}).
+			Get(st.ctx, &dst, key),
+		"No error expected on loading",
+	)
+	val, ok := st.local.Get(key, "")
+	st.Require().True(ok, "loaded value must be kept locally")
+	st.Require().Equal(st.keyToElement(key), string(val), "unexpected locally cached value")
+}
+
+func (st *LocalTierSuite) TestSetAndDeleteInvalidateLocalEntries() {
+	key := faker.RandomString(7)
+	hashKey := faker.RandomString(7)
+	field := faker.RandomString(4)
+	st.local.Set(key, "", []byte("stale"))
+	st.local.Set(hashKey, field, []byte("stale"))
+	st.local.Set(hashKey, "other-field", []byte("stale"))
+
+	st.Require().NoError(
+		st.tieredCache.Set(
+			st.ctx,
+			&cache.Item{Key: key, Value: "fresh"},
+			&cache.Item{Key: hashKey, Field: field, Value: "fresh"},
+		),
+		"No error expected on setting",
+	)
+	var dst string
+	st.Require().NoError(st.tieredCache.Get(st.ctx, &dst, key), "No error expected on getting the key")
+	st.Require().Equal("fresh", dst, "value set via the cache expected")
+	var fieldDst string
+	st.Require().NoError(st.tieredCache.HGetFieldsForKey(st.ctx, &fieldDst, hashKey, field), "No error expected on getting the field")
+	st.Require().Equal("fresh", fieldDst, "value set via the cache expected")
+
+	st.Require().NoError(st.tieredCache.Delete(st.ctx, key, hashKey), "No error expected on deleting")
+	for _, kf := range []string{key, cachekeys.KeyWithField(hashKey, field), cachekeys.KeyWithField(hashKey, "other-field")} {
+		_, ok := st.local.Get(cachekeys.SplitKeyAndField(kf))
+		st.Require().Falsef(ok, "%q must be removed from the local tier", kf)
+	}
+}
+
+func TestLocalTierSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &LocalTierSuite{})
+}
@@ -5,6 +5,12 @@ import (
 	"strconv"
 )
 
+// emptyDataUnmarshaller is implemented by the marshallers which might marshal values into empty data,
+// e.g. proto messages with default values, so such data can't be skipped
+type emptyDataUnmarshaller interface {
+	unmarshalEmptyData(dst interface{}) error
+}
+
 type baseMarshaller struct {
 	customMarshaller Marshaller
 }
@@ -59,6 +65,9 @@ func (m *baseMarshaller) Marshal(value interface{}) ([]byte, error) {
 //nolint:gocyclo // here we need to enumerate all the options
 func (m *baseMarshaller) Unmarshal(data []byte, dst interface{}) error {
 	if len(data) == 0 {
+		if u, ok := m.customMarshaller.(emptyDataUnmarshaller); ok {
+			return u.unmarshalEmptyData(dst)
+		}
 		return nil
 	}
 
@@ -0,0 +1,125 @@
+package marshallers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// compressedMagic starts every compressed value, it's followed by the compressor ID.
+// Neither JSON nor MessagePack nor Protocol Buffers values start with it,
+// so the values stored before the compression was enabled are read as is.
+const compressedMagic = "\x00\xc1"
+
+const compressedHeaderLen = len(compressedMagic) + 1
+
+// ErrUnknownCompressor is returned on reading a value compressed by another compressor
+var ErrUnknownCompressor = errors.New("unknown compressor")
+
+// Compressor compresses the marshalled values
+type Compressor interface {
+	// ID identifies the compression algorithm in the header of the compressed values
+	ID() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+type compressingMarshaller struct {
+	marshaller Marshaller
+	compressor Compressor
+	minSize    int
+}
+
+// NewCompressingMarshaller compresses the values of marshaller which are at least minSize bytes long.
+// The values which aren't compressed, including the ones stored without compression before, are read as is.
+// Wrap it with NewMarshaller to keep storing scalars as is:
+// NewMarshaller(NewCompressingMarshaller(&JSONMarshaller{}, &GzipCompressor{}, 1024))
+func NewCompressingMarshaller(marshaller Marshaller, compressor Compressor, minSize int) Marshaller {
+	return &compressingMarshaller{
+		marshaller: marshaller,
+		compressor: compressor,
+		minSize:    minSize,
+	}
+}
+
+func (m *compressingMarshaller) Marshal(value interface{}) ([]byte, error) {
+	data, marshalErr := m.marshaller.Marshal(value)
+	if marshalErr != nil || len(data) < m.minSize {
+		return data, marshalErr
+	}
+	compressed, compressErr := m.compressor.Compress(data)
+	if compressErr != nil {
+		return nil, errors.Wrap(compressErr, "compression failed")
+	}
+	if len(compressed)+compressedHeaderLen >= len(data) {
+		return data, nil
+	}
+	b := make([]byte, 0, compressedHeaderLen+len(compressed))
+	b = append(b, compressedMagic...)
+	b = append(b, m.compressor.ID())
+	return append(b, compressed...), nil
+}
+
+func (m *compressingMarshaller) Unmarshal(data []byte, dst interface{}) error {
+	if len(data) < compressedHeaderLen || string(data[:len(compressedMagic)]) != compressedMagic {
+		return m.marshaller.Unmarshal(data, dst)
+	}
+	if id := data[len(compressedMagic)]; id != m.compressor.ID() {
+		return errors.Wrapf(ErrUnknownCompressor, "compressor %d expected, %d given", m.compressor.ID(), id)
+	}
+	decompressed, decompressErr := m.compressor.Decompress(data[compressedHeaderLen:])
+	if decompressErr != nil {
+		return errors.Wrap(decompressErr, "decompression failed")
+	}
+	return m.marshaller.Unmarshal(decompressed, dst)
+}
+
+func (m *compressingMarshaller) unmarshalEmptyData(dst interface{}) error {
+	if u, ok := m.marshaller.(emptyDataUnmarshaller); ok {
+		return u.unmarshalEmptyData(dst)
+	}
+	return nil
+}
+
+const gzipCompressorID byte = 1
+
+// GzipCompressor compresses the values via gzip with the Level, gzip.DefaultCompression is used for 0
+type GzipCompressor struct {
+	Level int
+}
+
+func (c *GzipCompressor) ID() byte {
+	return gzipCompressorID
+}
+
+func (c *GzipCompressor) Compress(data []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, writerErr := gzip.NewWriterLevel(&buf, level)
+	if writerErr != nil {
+		return nil, writerErr
+	}
+	if _, writeErr := w.Write(data); writeErr != nil {
+		return nil, writeErr
+	}
+	if closeErr := w.Close(); closeErr != nil {
+		return nil, closeErr
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, readerErr := gzip.NewReader(bytes.NewReader(data))
+	if readerErr != nil {
+		return nil, readerErr
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+var _ Compressor = &GzipCompressor{}
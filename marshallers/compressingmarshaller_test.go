@@ -0,0 +1,70 @@
+package marshallers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompressingMarshallerSuite struct {
+	marshaller Marshaller
+	suite.Suite
+}
+
+const testCompressionMinSize = 64
+
+func (st *CompressingMarshallerSuite) SetupSuite() {
+	st.marshaller = NewMarshaller(NewCompressingMarshaller(&JSONMarshaller{}, &GzipCompressor{}, testCompressionMinSize))
+}
+
+func (st *CompressingMarshallerSuite) marshal(val interface{}) []byte {
+	marshalled, marshalErr := st.marshaller.Marshal(val)
+	st.Require().NoError(marshalErr, "No marshal error expected")
+	return marshalled
+}
+
+func (st *CompressingMarshallerSuite) Test_LargeValuesAreCompressed() {
+	expected := structureToSerialize{Field: strings.Repeat("repeated ", 100)}
+	marshalled := st.marshal(expected)
+	st.Require().True(strings.HasPrefix(string(marshalled), compressedMagic+string(gzipCompressorID)), "compressed value expected")
+
+	uncompressed, _ := (&JSONMarshaller{}).Marshal(expected)
+	st.Require().Less(len(marshalled), len(uncompressed), "compressed value must be smaller")
+
+	var dst structureToSerialize
+	st.Require().NoError(st.marshaller.Unmarshal(marshalled, &dst), "No unmarshal error expected")
+	st.Require().Equal(expected, dst, "Unexpected unmarshalled result")
+}
+
+func (st *CompressingMarshallerSuite) Test_SmallValuesAreKeptAsIs() {
+	val := structureToSerialize{Field: "f1"}
+	expected, _ := (&JSONMarshaller{}).Marshal(val)
+	st.Require().Equal(expected, st.marshal(val), "small values mustn't be compressed")
+}
+
+func (st *CompressingMarshallerSuite) Test_Scalars() {
+	large := strings.Repeat("s", 2*testCompressionMinSize)
+	st.Require().Equal(large, string(st.marshal(large)), "scalars must be stored as is")
+}
+
+func (st *CompressingMarshallerSuite) Test_UncompressedValuesAreRead() {
+	expected := structureToSerialize{Field: strings.Repeat("repeated ", 100)}
+	stored, _ := (&JSONMarshaller{}).Marshal(expected)
+
+	var dst structureToSerialize
+	st.Require().NoError(st.marshaller.Unmarshal(stored, &dst), "No unmarshal error expected")
+	st.Require().Equal(expected, dst, "Unexpected unmarshalled result")
+}
+
+func (st *CompressingMarshallerSuite) Test_UnknownCompressor() {
+	var dst structureToSerialize
+	unmarshalErr := st.marshaller.Unmarshal([]byte(compressedMagic+"\xff"+"data"), &dst)
+	st.Require().True(errors.Is(unmarshalErr, ErrUnknownCompressor), "ErrUnknownCompressor expected, %+v given", unmarshalErr)
+}
+
+func TestCompressingMarshallerSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &CompressingMarshallerSuite{})
+}
@@ -0,0 +1,129 @@
+package marshallers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// encryptedMagic starts every encrypted value, it's followed by the key ID length and the key ID
+const encryptedMagic = "\x00\xc2"
+
+// ErrDecryptionFailed is matched by all the DecryptionErr errors via errors.Is
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// DecryptionErr is returned if a value can't be decrypted, dst isn't changed then
+type DecryptionErr struct {
+	// KeyID is the ID of the key the value is encrypted with, it's empty if the value isn't encrypted
+	KeyID  string
+	Reason string
+}
+
+func (e *DecryptionErr) Error() string {
+	if e.KeyID == "" {
+		return fmt.Sprintf("%s: %s", ErrDecryptionFailed, e.Reason)
+	}
+	return fmt.Sprintf("%s with key %q: %s", ErrDecryptionFailed, e.KeyID, e.Reason)
+}
+
+func (e *DecryptionErr) Is(target error) bool {
+	return target == ErrDecryptionFailed
+}
+
+// EncryptionKeys are the AES keys of the encrypting marshaller
+type EncryptionKeys struct {
+	// CurrentKeyID is the ID of the key the values are encrypted with
+	CurrentKeyID string
+
+	// Keys are the AES keys by their IDs, 16, 24 or 32 bytes keys select AES-128, AES-192 or AES-256.
+	// The previous keys are kept to read the values encrypted before the rotation
+	Keys map[string][]byte
+}
+
+type encryptingMarshaller struct {
+	marshaller   Marshaller
+	currentKeyID string
+	aeads        map[string]cipher.AEAD
+}
+
+// NewEncryptingMarshaller encrypts the values of marshaller via AES-GCM.
+// The ID of the key is stored along with the value, so the values encrypted with all the keys are read.
+// Wrap NewMarshaller with it, so the scalars are encrypted as well:
+// NewEncryptingMarshaller(NewMarshaller(&JSONMarshaller{}), keys)
+func NewEncryptingMarshaller(marshaller Marshaller, keys EncryptionKeys) (Marshaller, error) {
+	if _, ok := keys.Keys[keys.CurrentKeyID]; !ok {
+		return nil, errors.Errorf("current key %q isn't found", keys.CurrentKeyID)
+	}
+	aeads := make(map[string]cipher.AEAD, len(keys.Keys))
+	for id, key := range keys.Keys {
+		if len(id) > 255 {
+			return nil, errors.Errorf("key ID %q is longer than 255 bytes", id)
+		}
+		block, blockErr := aes.NewCipher(key)
+		if blockErr != nil {
+			return nil, errors.Wrapf(blockErr, "invalid key %q", id)
+		}
+		aead, aeadErr := cipher.NewGCM(block)
+		if aeadErr != nil {
+			return nil, errors.Wrapf(aeadErr, "invalid key %q", id)
+		}
+		aeads[id] = aead
+	}
+	return &encryptingMarshaller{
+		marshaller:   marshaller,
+		currentKeyID: keys.CurrentKeyID,
+		aeads:        aeads,
+	}, nil
+}
+
+func (m *encryptingMarshaller) Marshal(value interface{}) ([]byte, error) {
+	data, marshalErr := m.marshaller.Marshal(value)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	aead := m.aeads[m.currentKeyID]
+	header := encryptedHeader(m.currentKeyID)
+	b := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(data)+aead.Overhead())
+	copy(b, header)
+	nonce := b[len(header):]
+	if _, randErr := rand.Read(nonce); randErr != nil {
+		return nil, errors.Wrap(randErr, "nonce generation failed")
+	}
+	// the header is authenticated, so the key ID can't be changed
+	return aead.Seal(b, nonce, data, header), nil
+}
+
+func (m *encryptingMarshaller) Unmarshal(data []byte, dst interface{}) error {
+	if len(data) < len(encryptedMagic)+1 || string(data[:len(encryptedMagic)]) != encryptedMagic {
+		return &DecryptionErr{Reason: "the value isn't encrypted"}
+	}
+	headerLen := len(encryptedMagic) + 1 + int(data[len(encryptedMagic)])
+	if len(data) < headerLen {
+		return &DecryptionErr{Reason: "the header is truncated"}
+	}
+	header := data[:headerLen]
+	keyID := string(header[len(encryptedMagic)+1:])
+	aead, ok := m.aeads[keyID]
+	if !ok {
+		return &DecryptionErr{KeyID: keyID, Reason: "unknown key"}
+	}
+	if len(data) < headerLen+aead.NonceSize() {
+		return &DecryptionErr{KeyID: keyID, Reason: "the nonce is truncated"}
+	}
+	nonce := data[headerLen : headerLen+aead.NonceSize()]
+	decrypted, openErr := aead.Open(nil, nonce, data[headerLen+aead.NonceSize():], header)
+	if openErr != nil {
+		return &DecryptionErr{KeyID: keyID, Reason: openErr.Error()}
+	}
+	return m.marshaller.Unmarshal(decrypted, dst)
+}
+
+func encryptedHeader(keyID string) []byte {
+	header := make([]byte, 0, len(encryptedMagic)+1+len(keyID))
+	header = append(header, encryptedMagic...)
+	header = append(header, byte(len(keyID)))
+	return append(header, keyID...)
+}
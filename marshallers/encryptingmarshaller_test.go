@@ -0,0 +1,94 @@
+package marshallers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+)
+
+type EncryptingMarshallerSuite struct {
+	suite.Suite
+}
+
+var (
+	testOldKey = bytes.Repeat([]byte{1}, 32)
+	testNewKey = bytes.Repeat([]byte{2}, 16)
+)
+
+func (st *EncryptingMarshallerSuite) marshaller(currentKeyID string, keys map[string][]byte) Marshaller {
+	m, initErr := NewEncryptingMarshaller(NewMarshaller(&JSONMarshaller{}), EncryptionKeys{
+		CurrentKeyID: currentKeyID,
+		Keys:         keys,
+	})
+	st.Require().NoError(initErr, "No init error expected")
+	return m
+}
+
+func (st *EncryptingMarshallerSuite) requireDecryptionErr(err error, keyID string) {
+	st.T().Helper()
+	st.Require().True(errors.Is(err, ErrDecryptionFailed), "ErrDecryptionFailed expected, %+v given", err)
+	var decryptionErr *DecryptionErr
+	st.Require().True(errors.As(err, &decryptionErr), "DecryptionErr expected, %+v given", err)
+	st.Require().Equal(keyID, decryptionErr.KeyID, "unexpected key ID")
+}
+
+func (st *EncryptingMarshallerSuite) Test_RoundTrip() {
+	m := st.marshaller("old", map[string][]byte{"old": testOldKey})
+	for _, val := range []interface{}{"user name", structureToSerialize{Field: "f1"}} {
+		marshalled, marshalErr := m.Marshal(val)
+		st.Require().NoError(marshalErr, "No marshal error expected")
+		st.Require().NotContains(string(marshalled), "f1", "the value must be encrypted")
+		st.Require().NotContains(string(marshalled), "user name", "the value must be encrypted")
+	}
+
+	marshalled, _ := m.Marshal(structureToSerialize{Field: "f1"})
+	var dst structureToSerialize
+	st.Require().NoError(m.Unmarshal(marshalled, &dst), "No unmarshal error expected")
+	st.Require().Equal(structureToSerialize{Field: "f1"}, dst, "Unexpected unmarshalled result")
+}
+
+func (st *EncryptingMarshallerSuite) Test_KeyRotation() {
+	oldMarshaller := st.marshaller("old", map[string][]byte{"old": testOldKey})
+	rotatedMarshaller := st.marshaller("new", map[string][]byte{"old": testOldKey, "new": testNewKey})
+	newMarshaller := st.marshaller("new", map[string][]byte{"new": testNewKey})
+
+	encryptedWithOld, _ := oldMarshaller.Marshal("old value")
+	var dst string
+	st.Require().NoError(rotatedMarshaller.Unmarshal(encryptedWithOld, &dst), "the old key must be used for the old values")
+	st.Require().Equal("old value", dst)
+
+	encryptedWithNew, _ := rotatedMarshaller.Marshal("new value")
+	st.Require().NoError(newMarshaller.Unmarshal(encryptedWithNew, &dst), "the current key must be used for the new values")
+	st.Require().Equal("new value", dst)
+
+	dst = "untouched"
+	st.requireDecryptionErr(newMarshaller.Unmarshal(encryptedWithOld, &dst), "old")
+	st.Require().Equal("untouched", dst, "dst mustn't be changed")
+}
+
+func (st *EncryptingMarshallerSuite) Test_DecryptionErrors() {
+	m := st.marshaller("old", map[string][]byte{"old": testOldKey})
+	var dst string
+
+	st.requireDecryptionErr(m.Unmarshal([]byte("plain value"), &dst), "")
+
+	marshalled, _ := m.Marshal("value")
+	marshalled[len(marshalled)-1] ^= 0xff
+	st.requireDecryptionErr(m.Unmarshal(marshalled, &dst), "old")
+	st.Require().Empty(dst, "dst mustn't be changed")
+}
+
+func (st *EncryptingMarshallerSuite) Test_InvalidKeys() {
+	_, initErr := NewEncryptingMarshaller(&JSONMarshaller{}, EncryptionKeys{CurrentKeyID: "absent", Keys: map[string][]byte{"k": testOldKey}})
+	st.Require().Error(initErr, "the current key must be present")
+
+	_, initErr = NewEncryptingMarshaller(&JSONMarshaller{}, EncryptionKeys{CurrentKeyID: "k", Keys: map[string][]byte{"k": []byte("short")}})
+	st.Require().Error(initErr, "the key size must be validated")
+}
+
+func TestEncryptingMarshallerSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &EncryptingMarshallerSuite{})
+}
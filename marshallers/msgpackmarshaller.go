@@ -0,0 +1,33 @@
+package marshallers
+
+import (
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgpackMarshaller encodes values in the MessagePack format,
+// it's more compact and faster than JSONMarshaller for large structures.
+// Wrap it with NewMarshaller to store scalars as is.
+type MsgpackMarshaller struct{}
+
+func (m *MsgpackMarshaller) Marshal(val interface{}) ([]byte, error) {
+	return msgpack.Marshal(val)
+}
+
+// Unmarshal decodes data into dst.
+// If dst is *interface{} holding a value, data is decoded into a new value of the same type,
+// otherwise MessagePack would replace it with a generic map
+func (m *MsgpackMarshaller) Unmarshal(data []byte, dst interface{}) error {
+	if iface, ok := dst.(*interface{}); ok && *iface != nil {
+		typed := reflect.New(reflect.TypeOf(*iface))
+		if err := msgpack.Unmarshal(data, typed.Interface()); err != nil {
+			return err
+		}
+		*iface = typed.Elem().Interface()
+		return nil
+	}
+	return msgpack.Unmarshal(data, dst)
+}
+
+var _ Marshaller = &MsgpackMarshaller{}
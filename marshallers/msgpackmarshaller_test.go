@@ -0,0 +1,76 @@
+package marshallers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MsgpackMarshallerSuite struct {
+	marshaller Marshaller
+	suite.Suite
+}
+
+func (st *MsgpackMarshallerSuite) SetupSuite() {
+	st.marshaller = NewMarshaller(&MsgpackMarshaller{})
+}
+
+func (st *MsgpackMarshallerSuite) marshal(val interface{}) []byte {
+	marshalled, marshalErr := st.marshaller.Marshal(val)
+	st.Require().NoError(marshalErr, "No marshal error expected")
+	return marshalled
+}
+
+func (st *MsgpackMarshallerSuite) Test_Scalars() {
+	for _, td := range marshallerTestData {
+		st.Run(td.testCase, func() {
+			st.Require().EqualValues(td.marshalled, string(st.marshal(td.unmarshalled)), "scalars must be stored as is")
+		})
+	}
+}
+
+func (st *MsgpackMarshallerSuite) Test_Struct() {
+	expected := structureToSerialize{Field: "f1"}
+	var dst structureToSerialize
+	st.Require().NoError(st.marshaller.Unmarshal(st.marshal(expected), &dst), "No unmarshal error expected")
+	st.Require().Equal(expected, dst, "Unexpected unmarshalled result")
+}
+
+func (st *MsgpackMarshallerSuite) Test_StructPointer() {
+	expected := &structureToSerialize{Field: "f1"}
+	var dst *structureToSerialize
+	st.Require().NoError(st.marshaller.Unmarshal(st.marshal(expected), &dst), "No unmarshal error expected")
+	st.Require().Equal(expected, dst, "Unexpected unmarshalled result")
+}
+
+func (st *MsgpackMarshallerSuite) Test_InterfaceDst() {
+	testData := []struct {
+		testCase string
+		value    interface{}
+	}{
+		{testCase: "struct", value: structureToSerialize{Field: "f1"}},
+		{testCase: "struct pointer", value: &structureToSerialize{Field: "f1"}},
+		{testCase: "slice", value: []string{"a", "b"}},
+	}
+	for _, td := range testData {
+		st.Run(td.testCase, func() {
+			// the destination keeps the type of the held value
+			var dst interface{}
+			switch td.value.(type) {
+			case structureToSerialize:
+				dst = structureToSerialize{}
+			case *structureToSerialize:
+				dst = &structureToSerialize{}
+			case []string:
+				dst = []string(nil)
+			}
+			st.Require().NoError(st.marshaller.Unmarshal(st.marshal(td.value), &dst), "No unmarshal error expected")
+			st.Require().Equal(td.value, dst, "Unexpected unmarshalled result")
+		})
+	}
+}
+
+func TestMsgpackMarshallerSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &MsgpackMarshallerSuite{})
+}
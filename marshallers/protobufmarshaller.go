@@ -0,0 +1,76 @@
+package marshallers
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotProtoMessage is returned by ProtobufMarshaller for the values and the destinations which aren't proto messages
+var ErrNotProtoMessage = errors.New("proto.Message expected")
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// ProtobufMarshaller encodes proto.Message values in the binary wire format.
+// Wrap it with NewMarshaller to store scalars as is.
+type ProtobufMarshaller struct{}
+
+func (m *ProtobufMarshaller) Marshal(val interface{}) ([]byte, error) {
+	msg, ok := val.(proto.Message)
+	if !ok {
+		return nil, errors.Wrapf(ErrNotProtoMessage, "%T value given", val)
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal decodes data into dst.
+// dst might be a message, e.g. *pb.User, a pointer to a message pointer, e.g. **pb.User,
+// or *interface{} holding a message: a new message of the same type is created then
+func (m *ProtobufMarshaller) Unmarshal(data []byte, dst interface{}) error {
+	if msg, ok := dst.(proto.Message); ok {
+		return proto.Unmarshal(data, msg)
+	}
+	target, msgType, ok := messageTarget(dst)
+	if !ok {
+		return errors.Wrapf(ErrNotProtoMessage, "%T destination given", dst)
+	}
+	msg := reflect.New(msgType.Elem())
+	if err := proto.Unmarshal(data, msg.Interface().(proto.Message)); err != nil {
+		return err
+	}
+	target.Set(msg)
+	return nil
+}
+
+// unmarshalEmptyData allocates a message for the empty data as the messages with default values are marshalled into it.
+// Other destinations are left as is
+func (m *ProtobufMarshaller) unmarshalEmptyData(dst interface{}) error {
+	if _, ok := dst.(proto.Message); !ok {
+		if _, _, ok := messageTarget(dst); !ok {
+			return nil
+		}
+	}
+	return m.Unmarshal(nil, dst)
+}
+
+// messageTarget returns the value a new message is set into and the message type
+// for the pointers to message pointers and *interface{} holding a message
+func messageTarget(dst interface{}) (target reflect.Value, msgType reflect.Type, ok bool) {
+	dstVal := reflect.ValueOf(dst)
+	if dstVal.Kind() != reflect.Ptr || dstVal.IsNil() {
+		return reflect.Value{}, nil, false
+	}
+	target = dstVal.Elem()
+	msgType = target.Type()
+	if target.Kind() == reflect.Interface && !target.IsNil() {
+		msgType = target.Elem().Type()
+	}
+	if msgType.Kind() != reflect.Ptr || !msgType.Implements(protoMessageType) {
+		return reflect.Value{}, nil, false
+	}
+	return target, msgType, true
+}
+
+var _ Marshaller = &ProtobufMarshaller{}
+var _ emptyDataUnmarshaller = &ProtobufMarshaller{}
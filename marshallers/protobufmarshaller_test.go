@@ -0,0 +1,93 @@
+package marshallers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type ProtobufMarshallerSuite struct {
+	marshaller Marshaller
+	suite.Suite
+}
+
+func (st *ProtobufMarshallerSuite) SetupSuite() {
+	st.marshaller = NewMarshaller(&ProtobufMarshaller{})
+}
+
+func (st *ProtobufMarshallerSuite) marshal(val interface{}) []byte {
+	marshalled, marshalErr := st.marshaller.Marshal(val)
+	st.Require().NoError(marshalErr, "No marshal error expected")
+	return marshalled
+}
+
+func (st *ProtobufMarshallerSuite) requireProtoEqual(expected proto.Message, actual interface{}) {
+	st.T().Helper()
+	actualMsg, ok := actual.(proto.Message)
+	st.Require().True(ok, "proto.Message expected, %T given", actual)
+	st.Require().True(proto.Equal(expected, actualMsg), "%v expected, %v given", expected, actualMsg)
+}
+
+func (st *ProtobufMarshallerSuite) Test_Scalars() {
+	for _, td := range marshallerTestData {
+		st.Run(td.testCase, func() {
+			st.Require().EqualValues(td.marshalled, string(st.marshal(td.unmarshalled)), "scalars must be stored as is")
+		})
+	}
+}
+
+func (st *ProtobufMarshallerSuite) Test_NonMessageValue() {
+	_, marshalErr := st.marshaller.Marshal(structureToSerialize{Field: "f1"})
+	st.Require().True(errors.Is(marshalErr, ErrNotProtoMessage), "ErrNotProtoMessage expected, %+v given", marshalErr)
+}
+
+func (st *ProtobufMarshallerSuite) Test_Unmarshal() {
+	expected := wrapperspb.String("value")
+	marshalled := st.marshal(expected)
+
+	st.Run("container element", func() {
+		// containers create the destinations this way
+		dst := reflect.New(reflect.TypeOf(wrapperspb.StringValue{})).Interface()
+		st.Require().NoError(st.marshaller.Unmarshal(marshalled, dst), "No unmarshal error expected")
+		st.requireProtoEqual(expected, dst)
+	})
+	st.Run("pointer to a nil message", func() {
+		var dst *wrapperspb.StringValue
+		st.Require().NoError(st.marshaller.Unmarshal(marshalled, &dst), "No unmarshal error expected")
+		st.requireProtoEqual(expected, dst)
+	})
+	st.Run("interface holding a message", func() {
+		var dst interface{} = &wrapperspb.StringValue{}
+		st.Require().NoError(st.marshaller.Unmarshal(marshalled, &dst), "No unmarshal error expected")
+		st.requireProtoEqual(expected, dst)
+	})
+}
+
+func (st *ProtobufMarshallerSuite) Test_UnmarshalDefaultValues() {
+	marshalled := st.marshal(wrapperspb.String(""))
+	st.Require().Empty(marshalled, "messages with default values are marshalled into empty data")
+
+	var dst *wrapperspb.StringValue
+	st.Require().NoError(st.marshaller.Unmarshal(marshalled, &dst), "No unmarshal error expected")
+	st.Require().NotNil(dst, "the message must be allocated for empty data")
+	st.requireProtoEqual(wrapperspb.String(""), dst)
+
+	var scalarDst string
+	st.Require().NoError(st.marshaller.Unmarshal(marshalled, &scalarDst), "scalars must be left as is for empty data")
+	st.Require().Empty(scalarDst)
+}
+
+func (st *ProtobufMarshallerSuite) Test_NonMessageDst() {
+	var dst structureToSerialize
+	unmarshalErr := st.marshaller.Unmarshal(st.marshal(wrapperspb.String("value")), &dst)
+	st.Require().True(errors.Is(unmarshalErr, ErrNotProtoMessage), "ErrNotProtoMessage expected, %+v given", unmarshalErr)
+}
+
+func TestProtobufMarshallerSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &ProtobufMarshallerSuite{})
+}
@@ -0,0 +1,195 @@
+package cache_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+type NegativeCacheSuite struct {
+	BaseCacheSuite
+	negativeCache *cache.Cache
+	loaderCalls   int32
+}
+
+const testNegativeTTL = 50 * time.Millisecond
+
+func (st *NegativeCacheSuite) SetupTest() {
+	atomic.StoreInt32(&st.loaderCalls, 0)
+	st.negativeCache = cache.NewCache(cache.Options{
+		Redis:       st.client,
+		Marshaller:  st.marshaller,
+		NegativeTTL: testNegativeTTL,
+	})
+}
+
+// loadingCache returns a cache which loader returns only the existing keys
+func (st *NegativeCacheSuite) loadingCache(existing map[string]string) *cache.Cache {
+	return st.negativeCache.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		atomic.AddInt32(&st.loaderCalls, 1)
+		res := map[string]string{}
+		for _, k := range absentKeys {
+			if v, ok := existing[k]; ok {
+				res[k] = v
+			}
+		}
+		return res, nil
+	})
+}
+
+func (st *NegativeCacheSuite) TestNotLoadedKeysAreKnownAbsent() {
+	existingKey := faker.RandomString(7)
+	absentKey := faker.RandomString(7)
+	c := st.loadingCache(map[string]string{existingKey: "val"})
+
+	var dst map[string]string
+	st.Require().NoError(c.Get(st.ctx, &dst, existingKey, absentKey), "No error expected on the first load")
+	st.Require().Equal(map[string]string{existingKey: "val"}, dst, "unexpected loaded values")
+
+	var cachedDst map[string]string
+	loadErr := c.Get(st.ctx, &cachedDst, existingKey, absentKey)
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(loadErr, &keyErr), "KeyErr expected, %+v given", loadErr)
+	st.Require().Len(keyErr.KeysToErrs, 1, "only the absent key error expected")
+	st.Require().True(errors.Is(keyErr.KeysToErrs[absentKey], cache.ErrKnownAbsent), "known absent error expected")
+	st.Require().Equal(1, keyErr.KnownAbsentErrsCount, "unexpected known absent errors count")
+	st.Require().False(keyErr.HasNonCacheMissErrs(), "known absent errors are cache misses")
+	st.Require().Equal(map[string]string{existingKey: "val"}, cachedDst, "unexpected cached values")
+	st.Require().EqualValues(1, atomic.LoadInt32(&st.loaderCalls), "the absent key mustn't be loaded again")
+}
+
+func (st *NegativeCacheSuite) TestSingleElementKnownAbsent() {
+	key := faker.RandomString(7)
+	var dst string
+	st.Require().NoError(st.loadingCache(nil).Get(st.ctx, &dst, key), "No error expected on the first load")
+
+	st.Require().True(errors.Is(st.negativeCache.Get(st.ctx, &dst, key), cache.ErrKnownAbsent), "known absent error expected")
+	st.Require().Equal(cache.ErrKnownAbsent, st.loadingCache(nil).Get(st.ctx, &dst, key), "known absent error expected with the loader")
+	st.Require().EqualValues(1, atomic.LoadInt32(&st.loaderCalls), "the absent key mustn't be loaded again")
+}
+
+func (st *NegativeCacheSuite) TestTombstoneExpires() {
+	key := faker.RandomString(7)
+	existing := map[string]string{}
+	c := st.loadingCache(existing)
+	var dst map[string]string
+	st.Require().NoError(c.Get(st.ctx, &dst, key), "No error expected on the first load")
+	st.Require().Empty(dst, "nothing must be loaded")
+
+	time.Sleep(testNegativeTTL)
+	existing[key] = "val"
+	st.Require().NoError(c.Get(st.ctx, &dst, key), "No error expected on the load after the tombstone expiration")
+	st.Require().Equal(map[string]string{key: "val"}, dst, "unexpected loaded values")
+	st.Require().EqualValues(2, atomic.LoadInt32(&st.loaderCalls), "the key must be loaded again")
+}
+
+func (st *NegativeCacheSuite) TestHashFieldTombstones() {
+	key := faker.RandomString(7)
+	existingField := faker.RandomString(4)
+	absentField := faker.RandomString(4)
+	st.Require().NoError(st.cache.HSetKV(st.ctx, key, existingField, "val"), "No error expected on setting")
+	c := st.loadingCache(nil)
+
+	var dst map[string]map[string]string
+	st.Require().NoError(c.HGetFieldsForKey(st.ctx, &dst, key, existingField, absentField), "No error expected on the first load")
+
+	loadErr := c.HGetFieldsForKey(st.ctx, &dst, key, existingField, absentField)
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(loadErr, &keyErr), "KeyErr expected, %+v given", loadErr)
+	st.Require().True(
+		errors.Is(keyErr.KeysToErrs[cachekeys.KeyWithField(key, absentField)], cache.ErrKnownAbsent),
+		"known absent error expected",
+	)
+	st.Require().Equal(map[string]map[string]string{key: {existingField: "val"}}, dst, "the existing field expected")
+	st.Require().EqualValues(1, atomic.LoadInt32(&st.loaderCalls), "the absent field mustn't be loaded again")
+
+	var allDst map[string]map[string]string
+	st.Require().NoError(st.negativeCache.HGetAll(st.ctx, &allDst, key), "tombstones mustn't fail reading the whole hash map")
+	st.Require().Equal(map[string]map[string]string{key: {existingField: "val"}}, allDst, "tombstones mustn't be returned")
+}
+
+func (st *NegativeCacheSuite) TestHashFieldTombstonesKeepHashMapTTL() {
+	c := cache.NewCache(cache.Options{
+		Redis:       st.client,
+		Marshaller:  st.marshaller,
+		DefaultTTL:  time.Hour,
+		NegativeTTL: time.Minute,
+	}).WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		return nil, nil
+	})
+	key := faker.RandomString(7)
+	existingField := faker.RandomString(4)
+	st.Require().NoError(st.cache.HSetKV(st.ctx, key, existingField, "val"), "No error expected on setting")
+	st.Require().NoError(st.client.Expire(st.ctx, key, 10*time.Second).Err(), "No error expected on expire")
+
+	var dst map[string]map[string]string
+	st.Require().NoError(c.HGetFieldsForKey(st.ctx, &dst, key, faker.RandomString(4)), "No error expected on loading")
+	ttl := st.client.PTTL(st.ctx, key).Val()
+	st.Require().True(ttl > 0 && ttl <= 10*time.Second, "the hash map TTL mustn't be changed, %s given", ttl)
+
+	newKey := faker.RandomString(7)
+	st.Require().NoError(c.HGetFieldsForKey(st.ctx, &dst, newKey, faker.RandomString(4)), "No error expected on loading")
+	newKeyTTL := st.client.PTTL(st.ctx, newKey).Val()
+	st.Require().True(newKeyTTL > 10*time.Second && newKeyTTL <= time.Minute, "NegativeTTL expected for the new hash map, %s given", newKeyTTL)
+}
+
+func (st *NegativeCacheSuite) TestHashMapTombstones() {
+	key := faker.RandomString(7)
+	c := st.loadingCache(nil)
+	var dst map[string]map[string]string
+	st.Require().NoError(c.HGetAll(st.ctx, &dst, key), "No error expected on the first load")
+
+	loadErr := c.HGetAll(st.ctx, &dst, key)
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(loadErr, &keyErr), "KeyErr expected, %+v given", loadErr)
+	st.Require().True(errors.Is(keyErr.KeysToErrs[key], cache.ErrKnownAbsent), "known absent error expected")
+	st.Require().EqualValues(1, atomic.LoadInt32(&st.loaderCalls), "the absent key mustn't be loaded again")
+
+	// the fields set later make the tombstone outdated
+	field := faker.RandomString(4)
+	st.Require().NoError(st.cache.HSetKV(st.ctx, key, field, "val"), "No error expected on setting")
+	st.Require().NoError(c.HGetAll(st.ctx, &dst, key), "No error expected for the hash map with fields")
+	st.Require().Equal(map[string]map[string]string{key: {field: "val"}}, dst, "unexpected hash map")
+}
+
+func (st *NegativeCacheSuite) TestNoTombstonesForFailedKeys() {
+	key := faker.RandomString(7)
+	c := st.negativeCache.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		atomic.AddInt32(&st.loaderCalls, 1)
+		return cache.LoadResult{KeysToErrs: map[string]error{key: errors.New("load failed")}}, nil
+	})
+	var dst map[string]string
+	for i := 0; i < 2; i++ {
+		loadErr := c.Get(st.ctx, &dst, key)
+		var keyErr *cache.KeyErr
+		st.Require().True(errors.As(loadErr, &keyErr), "KeyErr expected, %+v given", loadErr)
+		st.Require().Zero(keyErr.KnownAbsentErrsCount, "failed keys aren't known to be absent")
+	}
+	st.Require().EqualValues(2, atomic.LoadInt32(&st.loaderCalls), "the failed key must be loaded again")
+}
+
+func (st *NegativeCacheSuite) TestNoTombstonesWithoutNegativeTTL() {
+	key := faker.RandomString(7)
+	var loaderCalls int32
+	c := st.cache.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		atomic.AddInt32(&loaderCalls, 1)
+		return nil, nil
+	})
+	var dst map[string]string
+	for i := 0; i < 2; i++ {
+		st.Require().NoError(c.Get(st.ctx, &dst, key), "No error expected on loading")
+	}
+	st.Require().EqualValues(2, atomic.LoadInt32(&loaderCalls), "the absent key must be loaded every time")
+}
+
+func TestNegativeCacheSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &NegativeCacheSuite{})
+}
@@ -0,0 +1,93 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+type PatternDeleteSuite struct {
+	BaseCacheSuite
+	batchCache *cache.Cache
+	prefix     string
+}
+
+const testScanBatchSize = 3
+
+func (st *PatternDeleteSuite) SetupTest() {
+	st.batchCache = cache.NewCache(cache.Options{
+		Redis:         st.client,
+		Marshaller:    st.marshaller,
+		ScanBatchSize: testScanBatchSize,
+	})
+	st.prefix = faker.RandomString(10)
+}
+
+func (st *PatternDeleteSuite) setPrefixedKeys(count int) []string {
+	st.T().Helper()
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = cachekeys.CreateKey(st.prefix, faker.RandomString(8))
+	}
+	st.Require().NoError(st.batchCache.Set(st.ctx, st.items(keys)...), "No error expected on setting")
+	return keys
+}
+
+func (st *PatternDeleteSuite) items(keys []string) []*cache.Item {
+	items := make([]*cache.Item, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, &cache.Item{Key: k, Value: st.keyToElement(k)})
+	}
+	return items
+}
+
+func (st *PatternDeleteSuite) TestDeleteByPrefix() {
+	keys := st.setPrefixedKeys(10)
+	otherKey := st.prefix + faker.RandomString(8)
+	st.Require().NoError(st.batchCache.SetKV(st.ctx, otherKey, "val"), "No error expected on setting")
+
+	var progress []cache.DeleteByPatternStats
+	stats, err := st.batchCache.DeleteByPrefix(st.ctx, st.prefix, func(stats cache.DeleteByPatternStats) {
+		progress = append(progress, stats)
+	})
+	st.Require().NoError(err, "No error expected on deletion")
+	st.Require().Equal(cache.DeleteByPatternStats{Scanned: 10, Deleted: 10}, stats, "unexpected stats")
+	st.Require().NotEmpty(progress, "progress expected")
+	st.Require().Equal(stats, progress[len(progress)-1], "the last progress must be equal to the result")
+	for _, p := range progress {
+		st.Require().LessOrEqual(p.Scanned, int64(10), "the progress must be accumulated")
+	}
+
+	st.Require().EqualValues(0, st.client.Exists(st.ctx, keys...).Val(), "the prefixed keys must be deleted")
+	st.Require().EqualValues(1, st.client.Exists(st.ctx, otherKey).Val(), "the key without the separator must be kept")
+}
+
+func (st *PatternDeleteSuite) TestDeleteByPattern() {
+	keys := st.setPrefixedKeys(4)
+	stats, err := st.batchCache.DeleteByPattern(st.ctx, cachekeys.EscapePattern(st.prefix)+"*", nil)
+	st.Require().NoError(err, "No error expected on deletion")
+	st.Require().EqualValues(4, stats.Deleted, "unexpected deleted keys count")
+	st.Require().EqualValues(0, st.client.Exists(st.ctx, keys...).Val(), "the matched keys must be deleted")
+}
+
+func (st *PatternDeleteSuite) TestContextCancellation() {
+	keys := st.setPrefixedKeys(4)
+	ctx, cancel := context.WithCancel(st.ctx)
+	cancel()
+
+	stats, err := st.batchCache.DeleteByPrefix(ctx, st.prefix, nil)
+	st.Require().True(errors.Is(err, context.Canceled), "context.Canceled expected, %+v given", err)
+	st.Require().Zero(stats.Deleted, "nothing must be deleted")
+	st.Require().EqualValues(len(keys), st.client.Exists(st.ctx, keys...).Val(), "the keys must be kept")
+}
+
+func TestPatternDeleteSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &PatternDeleteSuite{})
+}
@@ -0,0 +1,50 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/marshallers"
+)
+
+type ProtobufSuite struct {
+	BaseCacheSuite
+	protoCache *cache.Cache
+}
+
+func (st *ProtobufSuite) SetupTest() {
+	st.protoCache = cache.NewCache(cache.Options{
+		Redis:      st.client,
+		Marshaller: marshallers.NewMarshaller(&marshallers.ProtobufMarshaller{}),
+	})
+}
+
+func (st *ProtobufSuite) TestDefaultValuedMessagesAreAllocated() {
+	defaultKey, valueKey := faker.RandomString(10), faker.RandomString(10)
+	st.Require().NoError(st.protoCache.SetKV(
+		st.ctx,
+		defaultKey, wrapperspb.String(""),
+		valueKey, wrapperspb.String("value"),
+	), "No error expected on setting")
+
+	var dst map[string]*wrapperspb.StringValue
+	st.Require().NoError(st.protoCache.Get(st.ctx, &dst, defaultKey, valueKey), "No error expected on getting")
+	st.Require().Len(dst, 2, "both messages expected")
+	st.Require().NotNil(dst[defaultKey], "the message with default values must be allocated")
+	st.Require().True(proto.Equal(wrapperspb.String(""), dst[defaultKey]), "unexpected default message %v", dst[defaultKey])
+	st.Require().True(proto.Equal(wrapperspb.String("value"), dst[valueKey]), "unexpected message %v", dst[valueKey])
+
+	var singleDst *wrapperspb.StringValue
+	st.Require().NoError(st.protoCache.Get(st.ctx, &singleDst, defaultKey), "No error expected on getting")
+	st.Require().NotNil(singleDst, "the message with default values must be allocated for a single element")
+}
+
+func TestProtobufSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &ProtobufSuite{})
+}
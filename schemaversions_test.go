@@ -0,0 +1,174 @@
+package cache_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+)
+
+type SchemaVersionsSuite struct {
+	BaseCacheSuite
+}
+
+type schemaUserV1 struct {
+	Name string
+}
+
+type schemaUser struct {
+	FirstName string
+	LastName  string
+}
+
+func (st *SchemaVersionsSuite) newCache(versions *cache.SchemaVersions) *cache.Cache {
+	return cache.NewCache(cache.Options{
+		Redis:          st.client,
+		Marshaller:     st.marshaller,
+		SchemaVersions: versions,
+	})
+}
+
+func (st *SchemaVersionsSuite) TestSameVersionIsRead() {
+	c := st.newCache(cache.NewSchemaVersions().Register(schemaUser{}, 2))
+	key := faker.RandomString(10)
+	st.Require().NoError(c.SetKV(st.ctx, key, &schemaUser{FirstName: "John", LastName: "Doe"}), "No error expected on setting")
+
+	var dst schemaUser
+	st.Require().NoError(c.Get(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Equal(schemaUser{FirstName: "John", LastName: "Doe"}, dst, "unexpected value")
+}
+
+func (st *SchemaVersionsSuite) TestOutdatedVersionIsCacheMiss() {
+	oldCache := st.newCache(cache.NewSchemaVersions().Register(schemaUser{}, 1))
+	newCache := st.newCache(cache.NewSchemaVersions().Register(schemaUser{}, 2))
+	key := faker.RandomString(10)
+	st.Require().NoError(oldCache.SetKV(st.ctx, key, schemaUser{FirstName: "John"}), "No error expected on setting")
+
+	var dst schemaUser
+	getErr := newCache.Get(st.ctx, &dst, key)
+	st.Require().True(errors.Is(getErr, cache.ErrCacheMiss), "cache miss expected, %+v given", getErr)
+
+	unversionedCache := cache.NewCache(cache.Options{
+		Redis:       st.client,
+		Marshaller:  st.marshaller,
+		NegativeTTL: time.Minute,
+	})
+	var unversionedDst schemaUser
+	st.Require().NoError(unversionedCache.Get(st.ctx, &unversionedDst, key), "the version mustn't be checked for the caches without SchemaVersions")
+	st.Require().Equal(schemaUser{FirstName: "John"}, unversionedDst, "unexpected value")
+}
+
+func (st *SchemaVersionsSuite) TestNewerVersionIsNotOverwritten() {
+	oldCache := st.newCache(cache.NewSchemaVersions().Register(schemaUser{}, 1))
+	newCache := st.newCache(cache.NewSchemaVersions().Register(schemaUser{}, 2))
+	key := faker.RandomString(10)
+	st.Require().NoError(newCache.SetKV(st.ctx, key, schemaUser{FirstName: "John", LastName: "Doe"}), "No error expected on setting")
+
+	var dst schemaUser
+	getErr := oldCache.
+		WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+			st.Require().Fail("the value of the newer version mustn't be reloaded")
+			return nil, nil
+		}).
+		Get(st.ctx, &dst, key)
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(getErr, &keyErr), "KeyErr expected, %+v given", getErr)
+	st.Require().True(errors.Is(keyErr.KeysToErrs[key], cache.ErrNewerSchemaVersion), "newer version error expected, %+v given", keyErr)
+
+	var newDst schemaUser
+	st.Require().NoError(newCache.Get(st.ctx, &newDst, key), "the value of the newer version must be kept")
+	st.Require().Equal(schemaUser{FirstName: "John", LastName: "Doe"}, newDst, "unexpected value")
+}
+
+func (st *SchemaVersionsSuite) TestUnversionedValueIsCacheMiss() {
+	c := st.newCache(cache.NewSchemaVersions().Register(schemaUser{}, 1))
+	key := faker.RandomString(10)
+	st.Require().NoError(st.cache.SetKV(st.ctx, key, schemaUser{FirstName: "John"}), "No error expected on setting")
+
+	var dst map[string]schemaUser
+	getErr := c.AddCacheMissErrors().Get(st.ctx, &dst, key)
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(getErr, &keyErr), "KeyErr expected, %+v given", getErr)
+	st.Require().True(errors.Is(keyErr.KeysToErrs[key], cache.ErrCacheMiss), "cache miss expected, %+v given", keyErr)
+	st.Require().Empty(dst, "the unversioned value mustn't be added")
+}
+
+func (st *SchemaVersionsSuite) TestOutdatedVersionIsReloaded() {
+	oldCache := st.newCache(cache.NewSchemaVersions().Register(schemaUser{}, 1))
+	newCache := st.newCache(cache.NewSchemaVersions().Register(schemaUser{}, 2))
+	key := faker.RandomString(10)
+	st.Require().NoError(oldCache.SetKV(st.ctx, key, schemaUser{FirstName: "John"}), "No error expected on setting")
+
+	var dst schemaUser
+	st.Require().NoError(
+		newCache.
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				st.Require().Equal([]string{key}, absentKeys, "unexpected keys to reload")
+				return &cache.Item{Key: key, Value: schemaUser{FirstName: "John", LastName: "Doe"}}, nil
+			}).
+			Get(st.ctx, &dst, key),
+		"No error expected on getting",
+	)
+	st.Require().Equal(schemaUser{FirstName: "John", LastName: "Doe"}, dst, "reloaded value expected")
+
+	var storedDst schemaUser
+	st.Require().NoError(newCache.Get(st.ctx, &storedDst, key), "the reloaded value must be stored with the current version")
+	st.Require().Equal(dst, storedDst, "unexpected stored value")
+}
+
+func (st *SchemaVersionsSuite) TestOutdatedVersionIsMigrated() {
+	var migratedFrom []uint32
+	versions := cache.NewSchemaVersions().
+		Register(schemaUser{}, 1).
+		RegisterMigration(schemaUser{}, 0, func(storedVersion uint32, payload []byte, dst interface{}) error {
+			migratedFrom = append(migratedFrom, storedVersion)
+			var old schemaUserV1
+			if unmarshalErr := st.marshaller.Unmarshal(payload, &old); unmarshalErr != nil {
+				return unmarshalErr
+			}
+			firstName, lastName, _ := strings.Cut(old.Name, " ")
+			*(dst.(*schemaUser)) = schemaUser{FirstName: firstName, LastName: lastName}
+			return nil
+		})
+	c := st.newCache(versions)
+	key := faker.RandomString(10)
+	field := faker.RandomString(5)
+	st.Require().NoError(st.cache.HSetKV(st.ctx, key, field, schemaUserV1{Name: "John Doe"}), "No error expected on setting")
+
+	var dst map[string]map[string]schemaUser
+	st.Require().NoError(c.HGetAll(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Equal(schemaUser{FirstName: "John", LastName: "Doe"}, dst[key][field], "migrated value expected")
+	st.Require().Equal([]uint32{0}, migratedFrom, "a single migration from the unversioned value expected")
+}
+
+func (st *SchemaVersionsSuite) TestFailedMigrationIsReported() {
+	migrationErr := errors.New("migration failed")
+	versions := cache.NewSchemaVersions().
+		Register(schemaUser{}, 2).
+		RegisterMigration(schemaUser{}, 1, func(_ uint32, _ []byte, dst interface{}) error {
+			dst.(*schemaUser).FirstName = "partially migrated"
+			return migrationErr
+		})
+	key := faker.RandomString(10)
+	st.Require().NoError(
+		st.newCache(cache.NewSchemaVersions().Register(schemaUser{}, 1)).SetKV(st.ctx, key, schemaUser{FirstName: "John"}),
+		"No error expected on setting",
+	)
+
+	dst := schemaUser{FirstName: "initial"}
+	getErr := st.newCache(versions).Get(st.ctx, &dst, key)
+	var keyErr *cache.KeyErr
+	st.Require().True(errors.As(getErr, &keyErr), "KeyErr expected, %+v given", getErr)
+	st.Require().True(errors.Is(keyErr.KeysToErrs[key], migrationErr), "migration error expected, %+v given", keyErr)
+	st.Require().Equal(schemaUser{FirstName: "initial"}, dst, "dst mustn't be changed by the failed migration")
+}
+
+func TestSchemaVersionsSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &SchemaVersionsSuite{})
+}
@@ -0,0 +1,159 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+var errCommandFailed = errors.New("command failed")
+
+// failingCmdsHook fails the pipelined commands with the given names for the given keys
+// after the pipeline is executed, so the rest of the commands are applied
+type failingCmdsHook struct {
+	mu          sync.Mutex
+	namesToKeys map[string]map[string]bool
+}
+
+func (h *failingCmdsHook) failFor(name, key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.namesToKeys == nil {
+		h.namesToKeys = map[string]map[string]bool{}
+	}
+	if h.namesToKeys[name] == nil {
+		h.namesToKeys[name] = map[string]bool{}
+	}
+	h.namesToKeys[name][key] = true
+}
+
+func (h *failingCmdsHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *failingCmdsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *failingCmdsHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *failingCmdsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, cmd := range cmds {
+		if key, ok := cmd.Args()[1].(string); ok && h.namesToKeys[cmd.Name()][key] {
+			cmd.SetErr(errCommandFailed)
+		}
+	}
+	return nil
+}
+
+type SetErrorsSuite struct {
+	BaseCacheSuite
+	hook         *failingCmdsHook
+	failingCache *cache.Cache
+}
+
+func (st *SetErrorsSuite) SetupTest() {
+	st.hook = &failingCmdsHook{}
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	client.AddHook(st.hook)
+	st.failingCache = cache.NewCache(cache.Options{
+		Redis:      client,
+		Marshaller: st.marshaller,
+	})
+}
+
+func (st *SetErrorsSuite) requireSetErr(err error) *cache.SetErr {
+	st.T().Helper()
+	var setErr *cache.SetErr
+	st.Require().True(errors.As(err, &setErr), "SetErr expected, %+v given", err)
+	return setErr
+}
+
+func (st *SetErrorsSuite) TestHashFieldsErrors() {
+	failedKey, okKey := faker.RandomString(10), faker.RandomString(10)
+	st.hook.failFor("hset", failedKey)
+
+	setErr := st.requireSetErr(st.failingCache.Set(
+		st.ctx,
+		&cache.Item{Key: failedKey, Field: "f1", Value: faker.RandomString(5)},
+		&cache.Item{Key: okKey, Field: "f1", Value: faker.RandomString(5)},
+		&cache.Item{Key: failedKey, Field: "f2", Value: faker.RandomString(5)},
+	))
+	st.Require().Len(setErr.KeysToErrs, 2, "both fields of the failed key expected")
+	for _, f := range []string{"f1", "f2"} {
+		keyErr := setErr.KeysToErrs[cachekeys.KeyWithField(failedKey, f)]
+		st.Require().True(errors.Is(keyErr, errCommandFailed), "command error expected for field %q, %+v given", f, keyErr)
+	}
+	st.Require().Empty(setErr.ExpireErrs, "no expire errors expected")
+}
+
+func (st *SetErrorsSuite) TestExpireErrorsAreReportedSeparately() {
+	key, okKey := faker.RandomString(10), faker.RandomString(10)
+	st.hook.failFor("expire", key)
+
+	results, err := st.failingCache.SetWithResults(
+		st.ctx,
+		&cache.Item{Key: key, Field: "f1", Value: faker.RandomString(5)},
+		&cache.Item{Key: okKey, Field: "f1", Value: faker.RandomString(5)},
+	)
+	setErr := st.requireSetErr(err)
+	st.Require().Empty(setErr.KeysToErrs, "the fields are written")
+	st.Require().Len(setErr.ExpireErrs, 1, "only the key with the failed expire expected")
+	st.Require().True(errors.Is(setErr.ExpireErrs[key], errCommandFailed), "expire error expected, %+v given", setErr.ExpireErrs[key])
+
+	st.Require().Equal(cache.SetWritten, results[0].Outcome)
+	st.Require().Error(results[0].ExpireErr, "expire error expected in the result")
+	st.Require().Equal(cache.SetWritten, results[1].Outcome)
+	st.Require().NoError(results[1].ExpireErr)
+}
+
+func (st *SetErrorsSuite) TestHSetKVErrors() {
+	key := faker.RandomString(10)
+	st.hook.failFor("expire", key)
+
+	setErr := st.requireSetErr(st.failingCache.HSetKV(st.ctx, key, "f1", faker.RandomString(5), "f2", faker.RandomString(5)))
+	st.Require().Empty(setErr.KeysToErrs, "the fields are written")
+	st.Require().Contains(setErr.ExpireErrs, key)
+
+	key = faker.RandomString(10)
+	st.hook.failFor("hset", key)
+	setErr = st.requireSetErr(st.failingCache.HSetKV(st.ctx, key, "f1", faker.RandomString(5), "f2", faker.RandomString(5)))
+	st.Require().Len(setErr.KeysToErrs, 2, "both fields must fail")
+	st.Require().Contains(setErr.KeysToErrs, cachekeys.KeyWithField(key, "f2"))
+}
+
+func (st *SetErrorsSuite) TestUnderlyingErrorsAreMatched() {
+	ctx, cancel := context.WithCancel(st.ctx)
+	cancel()
+	for _, items := range [][]*cache.Item{
+		{{Key: faker.RandomString(10), Value: "val"}},
+		{{Key: faker.RandomString(10), Value: "val"}, {Key: faker.RandomString(10), Field: "f", Value: "val"}},
+	} {
+		setErr := st.cache.Set(ctx, items...)
+		st.Require().True(errors.Is(setErr, context.Canceled), "context.Canceled expected for %d items, %+v given", len(items), setErr)
+		st.requireSetErr(setErr)
+	}
+
+	key := faker.RandomString(10)
+	st.hook.failFor("set", key)
+	setErr := st.failingCache.Set(st.ctx, &cache.Item{Key: key, Value: "val"}, &cache.Item{Key: faker.RandomString(10), Value: "val"})
+	st.Require().True(errors.Is(setErr, errCommandFailed), "the command error expected, %+v given", setErr)
+	st.Require().False(errors.Is(setErr, context.Canceled), "unrelated errors mustn't be matched")
+}
+
+func TestSetErrorsSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &SetErrorsSuite{})
+}
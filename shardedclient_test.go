@@ -0,0 +1,109 @@
+package cache_test
+
+import (
+	"context"
+	"hash/crc32"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/suite"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+)
+
+// shardedRediser routes every command to the shard owning its first key the same way *redis.Ring does.
+// The shards are different databases of the same server
+type shardedRediser struct {
+	*redis.Client
+	shards []*redis.Client
+}
+
+func (r *shardedRediser) shardFor(key string) *redis.Client {
+	return r.shards[int(crc32.ChecksumIEEE([]byte(key)))%len(r.shards)]
+}
+
+func (r *shardedRediser) Pipeline() redis.Pipeliner {
+	return &shardedPipeline{
+		Pipeliner: r.Client.Pipeline(),
+		rediser:   r,
+		pipelines: map[*redis.Client]redis.Pipeliner{},
+	}
+}
+
+// shardedPipeline supports only the reading commands
+type shardedPipeline struct {
+	redis.Pipeliner
+	rediser   *shardedRediser
+	pipelines map[*redis.Client]redis.Pipeliner
+	cmds      []redis.Cmder
+}
+
+func (p *shardedPipeline) pipelineFor(key string) redis.Pipeliner {
+	shard := p.rediser.shardFor(key)
+	if _, ok := p.pipelines[shard]; !ok {
+		p.pipelines[shard] = shard.Pipeline()
+	}
+	return p.pipelines[shard]
+}
+
+func (p *shardedPipeline) Get(ctx context.Context, key string) *redis.StringCmd {
+	cmd := p.pipelineFor(key).Get(ctx, key)
+	p.cmds = append(p.cmds, cmd)
+	return cmd
+}
+
+func (p *shardedPipeline) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	cmd := p.pipelineFor(keys[0]).MGet(ctx, keys...)
+	p.cmds = append(p.cmds, cmd)
+	return cmd
+}
+
+func (p *shardedPipeline) Exec(ctx context.Context) ([]redis.Cmder, error) {
+	var execErr error
+	for _, pipeliner := range p.pipelines {
+		if _, err := pipeliner.Exec(ctx); err != nil && err != redis.Nil && execErr == nil {
+			execErr = err
+		}
+	}
+	return p.cmds, execErr
+}
+
+type ShardedClientSuite struct {
+	BaseCacheSuite
+	rediser      *shardedRediser
+	shardedCache *cache.Cache
+}
+
+func (st *ShardedClientSuite) SetupTest() {
+	shards := []*redis.Client{
+		redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1}),
+		redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 2}),
+	}
+	st.rediser = &shardedRediser{Client: shards[0], shards: shards}
+	st.shardedCache = cache.NewCache(cache.Options{
+		Redis:      st.rediser,
+		Marshaller: st.marshaller,
+	})
+}
+
+func (st *ShardedClientSuite) TestKeysFromDifferentShardsAreRead() {
+	keys := st.randomKeys(20)
+	usedShards := map[*redis.Client]bool{}
+	for _, k := range keys {
+		shard := st.rediser.shardFor(k)
+		usedShards[shard] = true
+		val, marshalErr := st.marshaller.Marshal(st.keyToElement(k))
+		st.Require().NoError(marshalErr, "No error expected on marshalling")
+		st.Require().NoError(shard.Set(st.ctx, k, val, 0).Err(), "No error expected on setting")
+	}
+	st.Require().Len(usedShards, 2, "the keys are expected to be spread over all the shards")
+
+	var dst map[string]string
+	st.Require().NoError(st.shardedCache.AddCacheMissErrors().Get(st.ctx, &dst, keys...), "No error expected on getting")
+	st.Require().EqualValues(st.keysToMap(keys...), dst, "the keys from all the shards expected")
+}
+
+func TestShardedClientSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &ShardedClientSuite{})
+}
@@ -0,0 +1,177 @@
+package cache_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+	"github.com/vkuptcov/go-redis-cache/v8/cachekeys"
+)
+
+type StaleReadSuite struct {
+	BaseCacheSuite
+	swrCache *cache.Cache
+
+	mu          sync.Mutex
+	refreshErrs []error
+}
+
+const testSoftTTL = 50 * time.Millisecond
+
+func (st *StaleReadSuite) SetupTest() {
+	st.refreshErrs = nil
+	st.swrCache = cache.NewCache(cache.Options{
+		Redis:          st.client,
+		Marshaller:     st.marshaller,
+		DefaultSoftTTL: testSoftTTL,
+		OnBackgroundRefreshError: func(keys []string, err error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			st.refreshErrs = append(st.refreshErrs, err)
+		},
+	})
+}
+
+func (st *StaleReadSuite) TearDownTest() {
+	st.swrCache.Close()
+}
+
+func (st *StaleReadSuite) TestStaleValueIsReturnedAndRefreshed() {
+	key := faker.RandomString(7)
+	st.Require().NoError(st.swrCache.SetKV(st.ctx, key, "stale"), "No error expected on setting")
+	time.Sleep(testSoftTTL)
+
+	var loaderCalls int32
+	loadingCache := st.swrCache.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		atomic.AddInt32(&loaderCalls, 1)
+		st.Require().Equal([]string{key}, absentKeys, "only the stale key must be refreshed")
+		return &cache.Item{Key: key, Value: "fresh", SoftTTL: time.Minute}, nil
+	})
+
+	var dst string
+	st.Require().NoError(loadingCache.Get(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Equal("stale", dst, "stale value must be returned immediately")
+
+	st.Require().Eventually(func() bool {
+		var refreshed string
+		return st.swrCache.Get(st.ctx, &refreshed, key) == nil && refreshed == "fresh"
+	}, time.Second, 10*time.Millisecond, "the value must be refreshed in the background")
+	st.Require().EqualValues(1, atomic.LoadInt32(&loaderCalls), "single refresh expected")
+
+	var freshDst string
+	st.Require().NoError(loadingCache.Get(st.ctx, &freshDst, key), "No error expected on getting")
+	st.Require().Equal("fresh", freshDst, "refreshed value expected")
+	st.Require().EqualValues(1, atomic.LoadInt32(&loaderCalls), "fresh value mustn't be refreshed")
+}
+
+func (st *StaleReadSuite) TestStaleHashFieldIsRefreshed() {
+	key := faker.RandomString(7)
+	field := faker.RandomString(4)
+	st.Require().NoError(
+		st.swrCache.Set(st.ctx, &cache.Item{Key: key, Field: field, Value: "stale", SoftTTL: testSoftTTL}),
+		"No error expected on setting",
+	)
+	time.Sleep(testSoftTTL)
+
+	refreshed := make(chan []string, 1)
+	var dst map[string]string
+	st.Require().NoError(
+		st.swrCache.
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				refreshed <- absentKeys
+				return "fresh", nil
+			}).
+			HGetFieldsForKey(st.ctx, &dst, key, field),
+		"No error expected on getting",
+	)
+	st.Require().Equal(map[string]string{cachekeys.KeyWithField(key, field): "stale"}, dst, "stale value expected")
+	select {
+	case keys := <-refreshed:
+		st.Require().Equal([]string{cachekeys.KeyWithField(key, field)}, keys, "unexpected refreshed keys")
+	case <-time.After(time.Second):
+		st.Fail("the field must be refreshed")
+	}
+}
+
+func (st *StaleReadSuite) TestStaleValueWithoutLoader() {
+	key := faker.RandomString(7)
+	st.Require().NoError(st.swrCache.SetKV(st.ctx, key, 42), "No error expected on setting")
+	time.Sleep(testSoftTTL)
+
+	var dst int
+	st.Require().NoError(st.swrCache.Get(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Equal(42, dst, "stale value expected")
+	// caches using the envelopes for other features read the values with soft TTL as well
+	negativeCache := cache.NewCache(cache.Options{
+		Redis:       st.client,
+		Marshaller:  st.marshaller,
+		NegativeTTL: time.Minute,
+	})
+	var plainDst int
+	st.Require().NoError(negativeCache.Get(st.ctx, &plainDst, key), "No error expected on getting")
+	st.Require().Equal(42, plainDst, "stale value expected")
+}
+
+func (st *StaleReadSuite) TestRefreshErrorsAreReported() {
+	key := faker.RandomString(7)
+	st.Require().NoError(st.swrCache.SetKV(st.ctx, key, "stale"), "No error expected on setting")
+	time.Sleep(testSoftTTL)
+
+	refreshErr := errors.New("refresh failed")
+	var dst string
+	st.Require().NoError(
+		st.swrCache.
+			WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+				return nil, refreshErr
+			}).
+			Get(st.ctx, &dst, key),
+		"No error expected on getting",
+	)
+	st.Require().Equal("stale", dst, "stale value expected")
+	st.Require().Eventually(func() bool {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		return len(st.refreshErrs) == 1 && errors.Is(st.refreshErrs[0], refreshErr)
+	}, time.Second, 10*time.Millisecond, "refresh error must be reported")
+}
+
+func (st *StaleReadSuite) TestClosingDerivedCacheKeepsRefreshes() {
+	key := faker.RandomString(7)
+	st.Require().NoError(st.swrCache.SetKV(st.ctx, key, "stale"), "No error expected on setting")
+	time.Sleep(testSoftTTL)
+
+	loadingCache := st.swrCache.WithAbsentKeysLoader(func(absentKeys ...string) (interface{}, error) {
+		return &cache.Item{Key: key, Value: "fresh", SoftTTL: time.Minute}, nil
+	})
+	st.swrCache.WithTTL(time.Hour).Close()
+
+	var dst string
+	st.Require().NoError(loadingCache.Get(st.ctx, &dst, key), "No error expected on getting")
+	st.Require().Eventually(func() bool {
+		var refreshed string
+		return st.swrCache.Get(st.ctx, &refreshed, key) == nil && refreshed == "fresh"
+	}, time.Second, 10*time.Millisecond, "the refreshes must be kept after closing a derived cache")
+}
+
+func (st *StaleReadSuite) TestRawValuesLookingLikeEnvelopes() {
+	// the envelope magic, the envelope version and the tombstone flag
+	raw := "\x00\xe5\x01\x04raw"
+	for name, c := range map[string]*cache.Cache{"without envelopes": st.cache, "with envelopes": st.swrCache} {
+		key := faker.RandomString(10)
+		st.Require().NoErrorf(c.SetKV(st.ctx, key, raw), "No error expected on setting %s", name)
+		var dst string
+		st.Require().NoErrorf(c.Get(st.ctx, &dst, key), "No error expected on getting %s", name)
+		st.Require().Equalf(raw, dst, "the raw value must be read as is %s", name)
+	}
+}
+
+func TestStaleReadSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &StaleReadSuite{})
+}
@@ -0,0 +1,115 @@
+package cache
+
+import "context"
+
+// TypedCache is a type-safe facade over Cache for the values of the same type V.
+// The results are returned instead of being written into destinations,
+// so the destination type mistakes are caught on compilation.
+type TypedCache[V any] struct {
+	cache *Cache
+}
+
+// TypedLoader loads the keys absent in cache for TypedCache
+type TypedLoader[V any] struct {
+	// Load returns the loaded values by the cache keys.
+	// The keys which aren't returned are treated as not found
+	Load func(ctx context.Context, absentKeys ...string) (map[string]V, error)
+
+	// Name identifies the loader for Options.CoalesceLoads the same way as Loader.Name does
+	Name string
+}
+
+// NewTypedCache creates a typed facade over the cache.
+// All the options of the cache (TTL, loaders, local tier and so on) are kept.
+func NewTypedCache[V any](c *Cache) *TypedCache[V] {
+	return &TypedCache[V]{cache: c}
+}
+
+// Cache returns the underlying untyped cache
+func (tc *TypedCache[V]) Cache() *Cache {
+	return tc.cache
+}
+
+// Get returns the values found for the keys.
+// The values found are returned even if *KeyErr is returned for some of the keys
+func (tc *TypedCache[V]) Get(ctx context.Context, keys ...string) (map[string]V, error) {
+	dst := make(map[string]V, len(keys))
+	err := tc.cache.Get(ctx, &dst, keys...)
+	return dst, err
+}
+
+// GetOne returns the value for the key or ErrCacheMiss if there is no such key in cache
+func (tc *TypedCache[V]) GetOne(ctx context.Context, key string) (V, error) {
+	var dst V
+	err := tc.cache.Get(ctx, &dst, key)
+	return dst, err
+}
+
+// GetOrLoad returns the values for the keys, the keys absent in cache are loaded via the loader and cached
+func (tc *TypedCache[V]) GetOrLoad(ctx context.Context, loader TypedLoader[V], keys ...string) (map[string]V, error) {
+	dst := make(map[string]V, len(keys))
+	err := tc.cache.GetOrLoad(ctx, &dst, loader.untyped(), keys...)
+	return dst, err
+}
+
+// GetOneOrLoad returns the value for the key loading it via the loader if it's absent in cache.
+// ErrCacheMiss is returned if the loader doesn't return the key as well
+func (tc *TypedCache[V]) GetOneOrLoad(ctx context.Context, loader TypedLoader[V], key string) (V, error) {
+	loaded, err := tc.GetOrLoad(ctx, loader, key)
+	val, ok := loaded[key]
+	if err == nil && !ok {
+		err = ErrCacheMiss
+	}
+	return val, err
+}
+
+// Set stores the values by their keys with the cache default TTL
+func (tc *TypedCache[V]) Set(ctx context.Context, keysToVals map[string]V) error {
+	if len(keysToVals) == 0 {
+		return nil
+	}
+	items := make([]*Item, 0, len(keysToVals))
+	for k, v := range keysToVals {
+		items = append(items, &Item{Key: k, Value: v})
+	}
+	return tc.cache.Set(ctx, items...)
+}
+
+// HGetAll returns all the fields of the hash maps defined by the keys
+func (tc *TypedCache[V]) HGetAll(ctx context.Context, keys ...string) (map[string]map[string]V, error) {
+	dst := make(map[string]map[string]V, len(keys))
+	err := tc.cache.HGetAll(ctx, &dst, keys...)
+	return dst, err
+}
+
+// HGetFields returns the fields found in the hash map defined by the key
+func (tc *TypedCache[V]) HGetFields(ctx context.Context, key string, fields ...string) (map[string]V, error) {
+	dst := make(map[string]map[string]V, 1)
+	err := tc.cache.HGetFieldsForKey(ctx, &dst, key, fields...)
+	return dst[key], err
+}
+
+// HSet stores the fields in the hash map defined by the key with the cache default TTL
+func (tc *TypedCache[V]) HSet(ctx context.Context, key string, fieldsToVals map[string]V) error {
+	if len(fieldsToVals) == 0 {
+		return nil
+	}
+	items := make([]*Item, 0, len(fieldsToVals))
+	for f, v := range fieldsToVals {
+		items = append(items, &Item{Key: key, Field: f, Value: v})
+	}
+	return tc.cache.Set(ctx, items...)
+}
+
+func (l TypedLoader[V]) untyped() Loader {
+	return Loader{
+		Load: func(ctx context.Context, absentKeys ...string) (interface{}, error) {
+			loaded, err := l.Load(ctx, absentKeys...)
+			if err != nil || loaded == nil {
+				return nil, err
+			}
+			return loaded, nil
+		},
+		Name: l.Name,
+	}
+}
@@ -0,0 +1,160 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"syreclabs.com/go/faker"
+
+	cache "github.com/vkuptcov/go-redis-cache/v8"
+)
+
+type TypedCacheSuite struct {
+	BaseCacheSuite
+	users *cache.TypedCache[loadedUser]
+}
+
+func (st *TypedCacheSuite) SetupTest() {
+	st.users = cache.NewTypedCache[loadedUser](st.cache)
+}
+
+func (st *TypedCacheSuite) newUsers(count int) map[string]loadedUser {
+	users := make(map[string]loadedUser, count)
+	for i := 0; i < count; i++ {
+		id := faker.RandomString(7)
+		users[id] = loadedUser{ID: id, Name: faker.Name().Name()}
+	}
+	return users
+}
+
+func (st *TypedCacheSuite) TestSetAndGet() {
+	users := st.newUsers(3)
+	st.Require().NoError(st.users.Set(st.ctx, users), "No error expected on setting")
+
+	keys := make([]string, 0, len(users))
+	for k := range users {
+		keys = append(keys, k)
+	}
+	loaded, err := st.users.Get(st.ctx, keys...)
+	st.Require().NoError(err, "No error expected on getting")
+	st.Require().Equal(users, loaded, "unexpected users")
+
+	one, err := st.users.GetOne(st.ctx, keys[0])
+	st.Require().NoError(err, "No error expected on getting a single user")
+	st.Require().Equal(users[keys[0]], one, "unexpected user")
+
+	_, err = st.users.GetOne(st.ctx, faker.RandomString(7))
+	st.Require().True(errors.Is(err, cache.ErrCacheMiss), "cache miss expected, %+v given", err)
+}
+
+func (st *TypedCacheSuite) TestGetOrLoad() {
+	cached := st.newUsers(1)
+	st.Require().NoError(st.users.Set(st.ctx, cached), "No error expected on setting")
+	absent := st.newUsers(2)
+
+	keys := []string{}
+	for k := range cached {
+		keys = append(keys, k)
+	}
+	for k := range absent {
+		keys = append(keys, k)
+	}
+	notFoundKey := faker.RandomString(7)
+	keys = append(keys, notFoundKey)
+
+	loader := cache.TypedLoader[loadedUser]{
+		Load: func(ctx context.Context, absentKeys ...string) (map[string]loadedUser, error) {
+			st.Require().Len(absentKeys, len(absent)+1, "only the absent keys must be loaded")
+			return absent, nil
+		},
+	}
+	loaded, err := st.users.GetOrLoad(st.ctx, loader, keys...)
+	st.Require().NoError(err, "No error expected on loading")
+	st.Require().Len(loaded, len(cached)+len(absent), "the cached and loaded users expected")
+	for k, u := range absent {
+		st.Require().Equal(u, loaded[k], "unexpected loaded user")
+	}
+
+	_, err = st.users.GetOneOrLoad(st.ctx, cache.TypedLoader[loadedUser]{
+		Load: func(ctx context.Context, absentKeys ...string) (map[string]loadedUser, error) {
+			return nil, nil
+		},
+	}, notFoundKey)
+	st.Require().True(errors.Is(err, cache.ErrCacheMiss), "cache miss expected for the key not found by the loader, %+v given", err)
+
+	for k, u := range absent {
+		one, oneErr := st.users.GetOneOrLoad(st.ctx, cache.TypedLoader[loadedUser]{
+			Load: func(ctx context.Context, absentKeys ...string) (map[string]loadedUser, error) {
+				st.Fail("the loaded user must be cached")
+				return nil, nil
+			},
+		}, k)
+		st.Require().NoError(oneErr, "No error expected on getting the loaded user")
+		st.Require().Equal(u, one, "unexpected cached user")
+	}
+}
+
+func (st *TypedCacheSuite) TestLoadsAreCoalescedByName() {
+	users := cache.NewTypedCache[loadedUser](cache.NewCache(cache.Options{
+		Redis:         st.client,
+		Marshaller:    st.marshaller,
+		CoalesceLoads: true,
+	}))
+	key := faker.RandomString(7)
+	release := make(chan struct{})
+	var loaderCalls int32
+	loader := cache.TypedLoader[loadedUser]{
+		Name: "users",
+		Load: func(ctx context.Context, absentKeys ...string) (map[string]loadedUser, error) {
+			atomic.AddInt32(&loaderCalls, 1)
+			<-release
+			return map[string]loadedUser{key: {ID: key, Name: "user"}}, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	loaded := make([]loadedUser, 3)
+	for idx := range loaded {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			var loadErr error
+			loaded[idx], loadErr = users.GetOneOrLoad(st.ctx, loader, key)
+			st.Require().NoError(loadErr, "No error expected on loading")
+		}(idx)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	st.Require().EqualValues(1, atomic.LoadInt32(&loaderCalls), "only one loader call expected")
+	for _, u := range loaded {
+		st.Require().Equal(loadedUser{ID: key, Name: "user"}, u, "the shared user expected")
+	}
+}
+
+func (st *TypedCacheSuite) TestHashMaps() {
+	key := faker.RandomString(7)
+	users := st.newUsers(2)
+	st.Require().NoError(st.users.HSet(st.ctx, key, users), "No error expected on setting")
+
+	all, err := st.users.HGetAll(st.ctx, key)
+	st.Require().NoError(err, "No error expected on getting all the fields")
+	st.Require().Equal(map[string]map[string]loadedUser{key: users}, all, "unexpected hash map")
+
+	for field, u := range users {
+		fields, fieldsErr := st.users.HGetFields(st.ctx, key, field)
+		st.Require().NoError(fieldsErr, "No error expected on getting the fields")
+		st.Require().Equal(map[string]loadedUser{field: u}, fields, "unexpected fields")
+	}
+}
+
+func TestTypedCacheSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, &TypedCacheSuite{})
+}